@@ -0,0 +1,3 @@
+module github.com/ohtzz/syl
+
+go 1.24
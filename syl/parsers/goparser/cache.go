@@ -0,0 +1,177 @@
+package goparser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Cache stores parse results on disk so unchanged files aren't parsed again.
+// Entries are keyed by the file's path, size and modification time, the
+// options that shape the output and the version of the running tool.
+//
+// Editing a file or upgrading the tool leaves the old entries behind, so
+// every entry's modification time is bumped when it is used, and opening the
+// cache removes the entries unused for cacheMaxAge. The cache is therefore
+// bounded by the results of the files parsed within that window.
+type Cache struct {
+	dir     string
+	version string
+}
+
+// cacheEntry is what is stored for a file. Included is false for a file its
+// build constraints excluded, which is worth remembering as well. Generated
+// records whether the file carries the generated-code marker, so a later run
+// with SkipGenerated can skip it without reading it.
+type cacheEntry struct {
+	Included  bool     `json:"included"`
+	Generated bool     `json:"generated"`
+	Info      FileInfo `json:"info"`
+}
+
+// cacheMaxAge is how long an entry may go unused before it is removed
+const cacheMaxAge = 30 * 24 * time.Hour
+
+// DefaultCacheDir returns the syl directory under the user cache directory,
+// which is $XDG_CACHE_HOME on Linux
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "syl"), nil
+}
+
+// NewCache opens a cache in dir, creating it if needed, and removes the
+// entries unused for cacheMaxAge. It fails when dir can't be written, so
+// callers can go without a cache rather than fail every put. The running
+// executable's size and modification time stand in for its version
+// alongside SchemaVersion, so a rebuilt tool doesn't reuse stale results.
+func NewCache(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	probe, err := os.CreateTemp(dir, "entry-*")
+	if err != nil {
+		return nil, err
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	pruneCache(dir, time.Now().Add(-cacheMaxAge))
+
+	version := fmt.Sprint(SchemaVersion)
+	if exe, err := os.Executable(); err == nil {
+		if info, err := os.Stat(exe); err == nil {
+			version += fmt.Sprintf(" %d %d", info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return &Cache{dir: dir, version: version}, nil
+}
+
+// pruneCache removes the entries, and any temporary files left by an
+// interrupted put, last used before cutoff. Other files in dir are left
+// alone. Like put, it ignores errors since a leftover entry only costs disk
+// space.
+func pruneCache(dir string, cutoff time.Time) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		isEntry := len(name) == sha256.Size*2+len(".json") && filepath.Ext(name) == ".json"
+		if entry.IsDir() || !(isEntry || strings.HasPrefix(name, "entry-")) {
+			continue
+		}
+		info, err := entry.Info()
+		if err == nil && info.ModTime().Before(cutoff) {
+			os.Remove(filepath.Join(dir, entry.Name()))
+		}
+	}
+}
+
+// entryPath returns where the entry for the file at path, reported as name,
+// lives. It reports false when path can't be stat'ed.
+func (c *Cache) entryPath(name, path string, opts Options) (string, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	stat, err := os.Stat(abs)
+	if err != nil {
+		return "", false
+	}
+
+	tags := make([]string, 0, len(opts.BuildTags))
+	for tag := range opts.BuildTags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	filter := ""
+	if opts.Filter != nil {
+		filter = opts.Filter.String()
+	}
+
+	key := fmt.Sprintf("%s\x00%s\x00%s\x00%d\x00%d\x00%q\x00%t\x00%q\x00%q\x00%q\x00%t\x00%s",
+		c.version, abs, name, stat.Size(), stat.ModTime().UnixNano(),
+		opts.AnnotationPrefixes, opts.ExportedOnly, filter, opts.ErrorTypes, tags, opts.FindUnused, opts.Only)
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json"), true
+}
+
+// get returns the cached entry for the file, if there is one
+func (c *Cache) get(name, path string, opts Options) (cacheEntry, bool) {
+	entryPath, ok := c.entryPath(name, path, opts)
+	if !ok {
+		return cacheEntry{}, false
+	}
+	content, err := os.ReadFile(entryPath)
+	if err != nil {
+		return cacheEntry{}, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return cacheEntry{}, false
+	}
+	// Mark the entry used so pruning keeps it
+	now := time.Now()
+	os.Chtimes(entryPath, now, now)
+	return entry, true
+}
+
+// put stores the result for the file. Failing to write only costs a reparse
+// next time, so errors are ignored.
+func (c *Cache) put(name, path string, opts Options, entry cacheEntry) {
+	entryPath, ok := c.entryPath(name, path, opts)
+	if !ok {
+		return
+	}
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	// Write to a temporary file first so concurrent runs never read a
+	// partial entry
+	tmp, err := os.CreateTemp(c.dir, "entry-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	if err := os.Rename(tmp.Name(), entryPath); err != nil {
+		os.Remove(tmp.Name())
+	}
+}
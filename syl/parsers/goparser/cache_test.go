@@ -0,0 +1,167 @@
+package goparser
+
+import (
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// newTestCache opens a cache in a temporary directory and writes a Go file
+// for it to cache, returning both
+func newTestCache(t *testing.T) (*Cache, string) {
+	t.Helper()
+	cache, err := NewCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewCache: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "a.go")
+	if err := os.WriteFile(path, []byte("package p\n\nfunc A() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return cache, path
+}
+
+func TestCacheHit(t *testing.T) {
+	cache, path := newTestCache(t)
+	opts := DefaultOptions()
+	opts.Cache = cache
+
+	info, included, err := ParseTarget(token.NewFileSet(), "a.go", path, opts)
+	if err != nil || !included {
+		t.Fatalf("ParseTarget: included %v, err %v", included, err)
+	}
+	if _, ok := cache.get("a.go", path, opts); !ok {
+		t.Fatal("parsing didn't store an entry")
+	}
+
+	// A planted entry shows that the next parse is served from the cache
+	planted := info
+	planted.Package = "cached"
+	cache.put("a.go", path, opts, cacheEntry{Included: true, Info: planted})
+	info, _, err = ParseTarget(token.NewFileSet(), "a.go", path, opts)
+	if err != nil || info.Package != "cached" {
+		t.Errorf("second parse gave package %q, err %v; want the cached entry", info.Package, err)
+	}
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, path string, opts *Options)
+	}{
+		{"mtime", func(t *testing.T, path string, opts *Options) {
+			later := time.Now().Add(time.Hour)
+			if err := os.Chtimes(path, later, later); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"size", func(t *testing.T, path string, opts *Options) {
+			stat, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte("package p\n\nfunc A() {}\nfunc B() {}\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			// Keep the modification time so only the size differs
+			if err := os.Chtimes(path, stat.ModTime(), stat.ModTime()); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"exported only", func(t *testing.T, path string, opts *Options) { opts.ExportedOnly = true }},
+		{"filter", func(t *testing.T, path string, opts *Options) { opts.Filter = regexp.MustCompile("A") }},
+		{"build tags", func(t *testing.T, path string, opts *Options) { opts.BuildTags = map[string]bool{"linux": true} }},
+		{"annotation prefixes", func(t *testing.T, path string, opts *Options) { opts.AnnotationPrefixes = []string{"#"} }},
+		{"only", func(t *testing.T, path string, opts *Options) { opts.Only = "methods" }},
+		{"find unused", func(t *testing.T, path string, opts *Options) { opts.FindUnused = true }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, path := newTestCache(t)
+			opts := DefaultOptions()
+			cache.put("a.go", path, opts, cacheEntry{Included: true})
+			if _, ok := cache.get("a.go", path, opts); !ok {
+				t.Fatal("entry not found right after put")
+			}
+
+			tt.change(t, path, &opts)
+			if _, ok := cache.get("a.go", path, opts); ok {
+				t.Error("entry still found after the change")
+			}
+		})
+	}
+}
+
+func TestCachePrunesUnusedEntries(t *testing.T) {
+	cache, path := newTestCache(t)
+	opts := DefaultOptions()
+	cache.put("a.go", path, opts, cacheEntry{Included: true})
+	entryPath, _ := cache.entryPath("a.go", path, opts)
+
+	stale := filepath.Join(cache.dir, "entry-123")
+	other := filepath.Join(cache.dir, "notes.txt")
+	for _, name := range []string{stale, other} {
+		if err := os.WriteFile(name, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-2 * cacheMaxAge)
+	for _, name := range []string{entryPath, stale, other} {
+		if err := os.Chtimes(name, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Using the entry keeps it, while the unused temporary file goes
+	if _, ok := cache.get("a.go", path, opts); !ok {
+		t.Fatal("entry not found")
+	}
+	if _, err := NewCache(cache.dir); err != nil {
+		t.Fatalf("NewCache: %v", err)
+	}
+	if _, err := os.Stat(entryPath); err != nil {
+		t.Errorf("a recently used entry was pruned: %v", err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("an unused temporary file survived pruning: %v", err)
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("a file that isn't part of the cache was removed: %v", err)
+	}
+
+	if err := os.Chtimes(entryPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewCache(cache.dir); err != nil {
+		t.Fatalf("NewCache: %v", err)
+	}
+	if _, err := os.Stat(entryPath); !os.IsNotExist(err) {
+		t.Errorf("an unused entry survived pruning: %v", err)
+	}
+}
+
+func TestNewCacheUnwritable(t *testing.T) {
+	// A directory can't be created under a regular file, whoever runs this
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewCache(filepath.Join(file, "syl")); err == nil {
+		t.Error("NewCache succeeded in a directory that can't be created")
+	}
+
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0o555); err != nil {
+		t.Fatal(err)
+	}
+	if probe, err := os.CreateTemp(dir, "probe-*"); err == nil {
+		probe.Close()
+		t.Skip("read-only directories are writable for this user")
+	}
+	if _, err := NewCache(dir); err == nil {
+		t.Error("NewCache succeeded in a read-only directory")
+	}
+}
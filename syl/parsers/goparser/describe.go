@@ -0,0 +1,95 @@
+package goparser
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Schema returns a JSON Schema describing the output of this version of the
+// parser. It is built from the output types themselves, so it can't fall out
+// of step with them. The root is a FileInfo for a single file or a
+// MultiFileInfo for several, and the documents -matrix, -topo and -diff
+// produce are described under $defs as well. Fields without omitempty are
+// required, and slices and maps may be null.
+func Schema() map[string]interface{} {
+	defs := make(map[string]interface{})
+	for _, v := range []interface{}{FileInfo{}, MultiFileInfo{}, CallMatrix{}, TopoOrder{}, FunctionDiff{}} {
+		schemaFor(reflect.TypeOf(v), defs)
+	}
+
+	return map[string]interface{}{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title":   fmt.Sprintf("syl Go parser output, schema version %d", SchemaVersion),
+		"oneOf": []interface{}{
+			map[string]interface{}{"$ref": "#/$defs/FileInfo"},
+			map[string]interface{}{"$ref": "#/$defs/MultiFileInfo"},
+		},
+		"$defs": defs,
+	}
+}
+
+// schemaFor returns the schema of values of type t. Structs are added to defs
+// once under their name and referred to from there.
+func schemaFor(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":  []string{"array", "null"},
+			"items": schemaFor(t.Elem(), defs),
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 []string{"object", "null"},
+			"additionalProperties": schemaFor(t.Elem(), defs),
+		}
+	case reflect.Pointer:
+		return map[string]interface{}{
+			"anyOf": []interface{}{schemaFor(t.Elem(), defs), map[string]interface{}{"type": "null"}},
+		}
+	case reflect.Struct:
+		ref := map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+		if _, ok := defs[t.Name()]; ok {
+			return ref
+		}
+		// Claim the name first so recursive types refer back to it
+		defs[t.Name()] = nil
+
+		properties := make(map[string]interface{})
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = schemaFor(field.Type, defs)
+			if !strings.Contains(options, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		defs[t.Name()] = map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+		return ref
+	}
+	return map[string]interface{}{}
+}
@@ -0,0 +1,110 @@
+package goparser
+
+import "slices"
+
+// FunctionDiff compares the functions of two versions of a file. Functions
+// and methods are matched by name and receiver type; a matched pair whose
+// parameters, results, type parameters or receiver differ is Changed.
+type FunctionDiff struct {
+	SchemaVersion int             `json:"schema_version"`
+	Old           string          `json:"old"`
+	New           string          `json:"new"`
+	Added         []DiffEntry     `json:"added"`
+	Removed       []DiffEntry     `json:"removed"`
+	Changed       []SignatureDiff `json:"changed"`
+}
+
+// DiffEntry is a function present in only one of the versions
+type DiffEntry struct {
+	Name      string `json:"name"`
+	Receiver  string `json:"receiver"`
+	Signature string `json:"signature"`
+	Line      int    `json:"line"`
+}
+
+// SignatureDiff is a function whose signature differs between the versions
+type SignatureDiff struct {
+	Name         string `json:"name"`
+	Receiver     string `json:"receiver"`
+	OldSignature string `json:"old_signature"`
+	NewSignature string `json:"new_signature"`
+	OldLine      int    `json:"old_line"`
+	NewLine      int    `json:"new_line"`
+}
+
+// diffKey identifies a function across versions. A receiver switching
+// between T and *T keeps the key and shows up as a change.
+func diffKey(fn FunctionInfo) string {
+	if fn.IsMethod {
+		return receiverTypeName(fn.Receiver) + "." + fn.Name
+	}
+	return fn.Name
+}
+
+// sameSignature reports whether two versions of a function have the same
+// signature, ignoring parameter and result names
+func sameSignature(a, b FunctionInfo) bool {
+	return a.Receiver == b.Receiver && a.Returns == b.Returns &&
+		slices.Equal(a.Parameters, b.Parameters) && slices.Equal(a.TypeParams, b.TypeParams)
+}
+
+// DiffFunctions reports the functions added, removed and changed from oldInfo
+// to newInfo. Added and changed functions are in newInfo's order, removed ones
+// in oldInfo's. init functions are skipped since nothing can call them.
+func DiffFunctions(oldInfo, newInfo FileInfo) FunctionDiff {
+	diff := FunctionDiff{
+		SchemaVersion: SchemaVersion,
+		Old:           oldInfo.Filename,
+		New:           newInfo.Filename,
+		Added:         []DiffEntry{},
+		Removed:       []DiffEntry{},
+		Changed:       []SignatureDiff{},
+	}
+
+	index := func(functions []FunctionInfo) map[string]FunctionInfo {
+		byKey := make(map[string]FunctionInfo)
+		for _, fn := range functions {
+			if !fn.IsInit {
+				byKey[diffKey(fn)] = fn
+			}
+		}
+		return byKey
+	}
+	oldFuncs, newFuncs := index(oldInfo.Functions), index(newInfo.Functions)
+
+	for _, fn := range newInfo.Functions {
+		if fn.IsInit {
+			continue
+		}
+		old, ok := oldFuncs[diffKey(fn)]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, DiffEntry{
+				Name:      fn.Name,
+				Receiver:  fn.Receiver,
+				Signature: functionSignature(fn),
+				Line:      fn.StartLine,
+			})
+		case !sameSignature(old, fn):
+			diff.Changed = append(diff.Changed, SignatureDiff{
+				Name:         fn.Name,
+				Receiver:     fn.Receiver,
+				OldSignature: functionSignature(old),
+				NewSignature: functionSignature(fn),
+				OldLine:      old.StartLine,
+				NewLine:      fn.StartLine,
+			})
+		}
+	}
+	for _, fn := range oldInfo.Functions {
+		if _, ok := newFuncs[diffKey(fn)]; !ok && !fn.IsInit {
+			diff.Removed = append(diff.Removed, DiffEntry{
+				Name:      fn.Name,
+				Receiver:  fn.Receiver,
+				Signature: functionSignature(fn),
+				Line:      fn.StartLine,
+			})
+		}
+	}
+	return diff
+}
@@ -0,0 +1,44 @@
+package goparser
+
+import "testing"
+
+func TestDiffCompositeReturnChange(t *testing.T) {
+	oldInfo := parseSource(t, `package p
+
+type T struct{}
+
+func Get() []byte { return nil }
+func Find() *T { return nil }
+func Same() map[string]int { return nil }
+`)
+	newInfo := parseSource(t, `package p
+
+type T struct{}
+
+func Get() map[string]int { return nil }
+func Find() []*T { return nil }
+func Same() map[string]int { return nil }
+`)
+
+	diff := DiffFunctions(oldInfo, newInfo)
+	if len(diff.Added) != 0 || len(diff.Removed) != 0 {
+		t.Errorf("expected no added or removed functions, got %+v and %+v", diff.Added, diff.Removed)
+	}
+
+	tests := []struct {
+		name, oldSig, newSig string
+	}{
+		{"Get", "func Get() []byte", "func Get() map[string]int"},
+		{"Find", "func Find() *T", "func Find() []*T"},
+	}
+	if len(diff.Changed) != len(tests) {
+		t.Fatalf("got %d changed functions, want %d: %+v", len(diff.Changed), len(tests), diff.Changed)
+	}
+	for i, tt := range tests {
+		got := diff.Changed[i]
+		if got.Name != tt.name || got.OldSignature != tt.oldSig || got.NewSignature != tt.newSig {
+			t.Errorf("changed[%d] = %s %q -> %q, want %s %q -> %q",
+				i, got.Name, got.OldSignature, got.NewSignature, tt.name, tt.oldSig, tt.newSig)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package goparser
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// FencedBlock is a ```go fenced code block from a Markdown document. Line is
+// the line of its opening fence, and Source the code between the fences with
+// the fence's indentation removed.
+type FencedBlock struct {
+	Index  int
+	Line   int
+	Source string
+}
+
+// ExtractGoBlocks returns the fenced code blocks of a Markdown document whose
+// info string names Go, go or golang, numbered from 0. Fences of backticks or
+// tildes are recognized, and a block left open runs to the end of the
+// document.
+func ExtractGoBlocks(content []byte) []FencedBlock {
+	var blocks []FencedBlock
+	lines := strings.Split(string(content), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r")
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if indent > 3 {
+			continue
+		}
+		fence, info, ok := openingFence(line[indent:])
+		if !ok {
+			continue
+		}
+
+		var code []string
+		start := i
+		for i++; i < len(lines); i++ {
+			body := strings.TrimRight(lines[i], "\r")
+			if trimmed := strings.TrimLeft(body, " "); len(body)-len(trimmed) <= 3 && isClosingFence(trimmed, fence) {
+				break
+			}
+			// Drop up to the fence's own indentation from each line
+			for j := 0; j < indent && strings.HasPrefix(body, " "); j++ {
+				body = body[1:]
+			}
+			code = append(code, body)
+		}
+
+		lang, _, _ := strings.Cut(info, " ")
+		if lang == "go" || lang == "golang" {
+			blocks = append(blocks, FencedBlock{
+				Index:  len(blocks),
+				Line:   start + 1,
+				Source: strings.Join(code, "\n"),
+			})
+		}
+	}
+	return blocks
+}
+
+// openingFence reports whether line opens a fenced code block, returning the
+// fence and the lowercased info string after it
+func openingFence(line string) (string, string, bool) {
+	for _, c := range []string{"`", "~"} {
+		n := len(line) - len(strings.TrimLeft(line, c))
+		if n < 3 {
+			continue
+		}
+		info := strings.TrimSpace(line[n:])
+		// A backtick fence's info string can't contain backticks
+		if c == "`" && strings.Contains(info, "`") {
+			return "", "", false
+		}
+		return line[:n], strings.ToLower(info), true
+	}
+	return "", "", false
+}
+
+// isClosingFence reports whether line closes a block opened with fence: at
+// least as many of the same character and nothing else
+func isClosingFence(line, fence string) bool {
+	line = strings.TrimRight(line, " \t")
+	n := len(line) - len(strings.TrimLeft(line, fence[:1]))
+	return n >= len(fence) && n == len(line)
+}
+
+// ParseMarkdownBlocks parses each Go block of the Markdown document, reported
+// as name#index with its BlockIndex and BlockLine set. A block without a
+// package clause is given a synthetic package main. Either way one line is
+// put in front of the code, so line n of a block is line BlockLine+n-1 of the
+// document. A block with syntax errors is recorded in Errors, and still in
+// Files with its ParseErrors when anything of it was recovered.
+func ParseMarkdownBlocks(fSet *token.FileSet, name string, content []byte, opts Options, multi *MultiFileInfo) {
+	for _, block := range ExtractGoBlocks(content) {
+		blockName := fmt.Sprintf("%s#%d", name, block.Index)
+
+		header := "\n"
+		if _, err := parser.ParseFile(token.NewFileSet(), "", block.Source, parser.PackageClauseOnly); err != nil {
+			header = "package main\n"
+		}
+		info, err := Parse(fSet, blockName, []byte(header+block.Source), opts)
+		if err != nil && len(info.ParseErrors) == 0 {
+			multi.Errors[blockName] = err.Error()
+			continue
+		}
+		if len(info.ParseErrors) > 0 {
+			multi.Errors[blockName] = strings.Join(info.ParseErrors, "; ")
+		}
+
+		index := block.Index
+		info.BlockIndex = &index
+		info.BlockLine = block.Line
+		if opts.OnFile != nil {
+			opts.OnFile(blockName, info)
+			continue
+		}
+		multi.Files[blockName] = info
+	}
+}
+
+// ParseMarkdownFiles parses the Go blocks of each Markdown target, which may
+// also be "-" for stdin. A target that can't be read is recorded in
+// Errors rather than ending the run.
+func ParseMarkdownFiles(fSet *token.FileSet, targets []string, opts Options) MultiFileInfo {
+	multi := MultiFileInfo{
+		SchemaVersion: SchemaVersion,
+		Files:         make(map[string]FileInfo),
+		Errors:        make(map[string]string),
+	}
+
+	for _, target := range targets {
+		content, err := ReadSource(target)
+		if err != nil {
+			multi.Errors[target] = err.Error()
+			continue
+		}
+		ParseMarkdownBlocks(fSet, target, content, opts, &multi)
+	}
+	multi.Packages = GroupPackages(multi.Files)
+	return multi
+}
@@ -0,0 +1,4769 @@
+// Package goparser extracts functions, types and declarations from Go source
+// files into the structures the syl Go parser emits as JSON.
+package goparser
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/build/constraint"
+	"go/constant"
+	"go/doc/comment"
+	"go/parser"
+	"go/printer"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// SchemaVersion identifies the structure of the emitted JSON. Bump it whenever
+// a field is added, removed or changes meaning so consumers can adapt.
+const SchemaVersion = 76
+
+type FunctionInfo struct {
+	Name               string     `json:"name"`
+	StartLine          int        `json:"start_line"`
+	EndLine            int        `json:"end_line"`
+	StartCol           int        `json:"start_col"`
+	EndCol             int        `json:"end_col"`
+	Parameters         []string   `json:"parameters"`
+	ParameterNames     []string   `json:"parameter_names"`
+	IsVariadic         bool       `json:"is_variadic"`
+	Returns            string     `json:"returns"`
+	ReturnCount        int        `json:"return_count"`
+	ReturnNames        []string   `json:"return_names"`
+	Calls              []string   `json:"calls"`
+	ExternalCalls      []string   `json:"external_calls"`
+	IsMethod           bool       `json:"is_method"`
+	Receiver           string     `json:"receiver"`
+	ReceiverTypeParams []string   `json:"receiver_type_params"`
+	DocString          string     `json:"docstring"`
+	DocStringRaw       string     `json:"docstring_raw"`
+	DocBlocks          []DocBlock `json:"doc_blocks"`
+	MissingDoc         bool       `json:"missing_doc"`
+	RawCode            string     `json:"raw_code"`
+	TypeParams         []string   `json:"type_params"`
+	Complexity         int        `json:"complexity"`
+	MaxNestingDepth    int        `json:"max_nesting_depth"`
+	StatementCount     int        `json:"statement_count"`
+	BodyHash           string     `json:"body_hash"`
+
+	StringConcatInLoop  bool     `json:"string_concat_in_loop"`
+	Pragmas             []string `json:"pragmas"`
+	RecoverIgnoresValue bool     `json:"recover_ignores_value"`
+	CouldBeMethod       bool     `json:"could_be_method"`
+	ReceiverUnused      bool     `json:"receiver_unused"`
+	NonExhaustiveSwitch bool     `json:"non_exhaustive_switch"`
+	WrapsErrors         bool     `json:"wraps_errors"`
+	ErrorfWrapped       int      `json:"errorf_wrapped"`
+	ErrorfUnwrapped     int      `json:"errorf_unwrapped"`
+
+	AppendWithoutPrealloc bool     `json:"append_without_prealloc"`
+	Defers                []string `json:"defers"`
+	SignatureTypeCount    int      `json:"signature_type_count"`
+	ReadsGlobals          []string `json:"reads_globals"`
+	WritesGlobals         []string `json:"writes_globals"`
+	Annotations           []string `json:"annotations"`
+	IsPure                bool     `json:"is_pure"`
+	IsRecursive           bool     `json:"is_recursive"`
+	FanIn                 int      `json:"fan_in"`
+	FanOut                int      `json:"fan_out"`
+	IsTest                bool     `json:"is_test"`
+	TestKind              string   `json:"test_kind"`
+	IsInit                bool     `json:"is_init"`
+	InitOrder             int      `json:"init_order"`
+	IsDeprecated          bool     `json:"is_deprecated"`
+	DeprecationNote       string   `json:"deprecation_note"`
+	ReturnsError          bool     `json:"returns_error"`
+	ErrorIsLast           bool     `json:"error_is_last"`
+	TakesContext          bool     `json:"takes_context"`
+	ContextIsFirst        bool     `json:"context_is_first"`
+	HasDefer              bool     `json:"has_defer"`
+	HasGoroutine          bool     `json:"has_goroutine"`
+	HasPanic              bool     `json:"has_panic"`
+	HasGoto               bool     `json:"has_goto"`
+	HasLabeledBranch      bool     `json:"has_labeled_branch"`
+	Labels                []string `json:"labels"`
+	GoCallSites           []string `json:"go_call_sites"`
+	UsedImports           []string `json:"used_imports"`
+	ResolvedParameters    []string `json:"resolved_parameters,omitempty"`
+	ResolvedReturns       string   `json:"resolved_returns,omitempty"`
+}
+
+type FileInfo struct {
+	SchemaVersion int            `json:"schema_version"`
+	Filename      string         `json:"filename"`
+	Package       string         `json:"package"`
+	Functions     []FunctionInfo `json:"functions"`
+	Imports       []string       `json:"imports"`
+	ImportSpecs   []ImportInfo   `json:"import_specs"`
+	ParseErrors   []string       `json:"parse_errors"`
+	UnknownTypes  []string       `json:"unknown_types"`
+
+	SingleCallerHelpers []string        `json:"single_caller_helpers"`
+	Structs             []StructInfo    `json:"structs"`
+	Interfaces          []InterfaceInfo `json:"interfaces"`
+	Types               []TypeInfo      `json:"types"`
+	Exports             []Export        `json:"exports"`
+	Constants           []ValueInfo     `json:"constants"`
+	Variables           []ValueInfo     `json:"variables"`
+	EnumCoverage        []EnumCoverage  `json:"enum_coverage"`
+	Switches            []SwitchInfo    `json:"switches"`
+	CallGraph           []Edge          `json:"call_graph"`
+	Markers             []Marker        `json:"markers"`
+	Closures            []ClosureInfo   `json:"closures"`
+	Metrics             Metrics         `json:"metrics"`
+	Summary             Summary         `json:"summary"`
+
+	// BlockIndex and BlockLine are only set for a Go block parsed out of a
+	// Markdown document: its number and the line of its opening fence
+	BlockIndex *int `json:"block_index,omitempty"`
+	BlockLine  int  `json:"block_line,omitempty"`
+
+	// UnusedFunctions and UnusedNote are only filled in with FindUnused
+	UnusedFunctions []string `json:"unused_functions,omitempty"`
+	UnusedNote      string   `json:"unused_note,omitempty"`
+}
+
+// ImportInfo is one import in source order. Alias is the name it is imported
+// under, including _ and ., and empty without one. Group numbers the
+// blank-line separated groups from 0, with each import declaration starting
+// a new group.
+type ImportInfo struct {
+	Path  string `json:"path"`
+	Alias string `json:"alias"`
+	Group int    `json:"group"`
+	Line  int    `json:"line"`
+}
+
+// DocBlock is one block of a doc comment. Kind is code for an indented code
+// block, kept verbatim, and text for anything else: paragraphs, headings and
+// lists, with lists rendered one "- item" per line.
+type DocBlock struct {
+	Kind string `json:"kind"`
+	Text string `json:"text"`
+}
+
+// ClosureInfo is a function literal. Path lists the enclosing functions and
+// closures from the outermost in.
+type ClosureInfo struct {
+	Name              string   `json:"name"`
+	Parameters        []string `json:"parameters"`
+	Returns           string   `json:"returns"`
+	StartLine         int      `json:"start_line"`
+	EndLine           int      `json:"end_line"`
+	EnclosingFunction string   `json:"enclosing_function"`
+	Path              []string `json:"path"`
+}
+
+// Metrics counts the file's lines by kind
+type Metrics struct {
+	TotalLines    int `json:"total_lines"`
+	CodeLines     int `json:"code_lines"`
+	CommentLines  int `json:"comment_lines"`
+	BlankLines    int `json:"blank_lines"`
+	FunctionCount int `json:"function_count"`
+}
+
+// Summary rolls up the cyclomatic complexity of the file's functions.
+// MostComplexFunction names the first one with MaxComplexity, as
+// Receiver.Method for methods.
+type Summary struct {
+	TotalComplexity     int     `json:"total_complexity"`
+	AverageComplexity   float64 `json:"average_complexity"`
+	MaxComplexity       int     `json:"max_complexity"`
+	MostComplexFunction string  `json:"most_complex_function"`
+}
+
+// Marker is a TODO, FIXME, HACK or XXX comment
+type Marker struct {
+	Tag  string `json:"tag"`
+	Text string `json:"text"`
+	Line int    `json:"line"`
+}
+
+// Edge is a call from one function to another defined in the same file
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// EnumCoverage tells which members of a local enum-like type are handled by
+// at least one switch statement
+type EnumCoverage struct {
+	Type      string   `json:"type"`
+	Switches  int      `json:"switches"`
+	Handled   []string `json:"handled"`
+	Unhandled []string `json:"unhandled"`
+}
+
+// SwitchInfo is an expression switch with a tag. Cases lists the distinct
+// case values that are plain identifiers, which is how enum members are
+// matched; other case values are left out.
+type SwitchInfo struct {
+	Line       int      `json:"line"`
+	Cases      []string `json:"cases"`
+	HasDefault bool     `json:"has_default"`
+}
+
+// ValueInfo is a package-level const or var. Type is the declared type, empty
+// when it is inferred. Value is the initializer source; EvaluatedValue is the
+// folded result when it can be computed from the file. Group numbers the
+// const or var declarations from 0 in source order, so members of one
+// parenthesized block share it, and Grouped tells whether it is such a block.
+type ValueInfo struct {
+	Name           string `json:"name"`
+	Type           string `json:"type"`
+	Line           int    `json:"line"`
+	Value          string `json:"value"`
+	EvaluatedValue string `json:"evaluated_value"`
+	Group          int    `json:"group"`
+	Grouped        bool   `json:"grouped"`
+	DocString      string `json:"docstring"`
+}
+
+// Export is an exported identifier declared in the file. Kind is one of
+// function, method, type, const or var.
+type Export struct {
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	Receiver string `json:"receiver"`
+	Line     int    `json:"line"`
+}
+
+// JSONField is one key a struct produces when marshaled with encoding/json
+type JSONField struct {
+	Name       string `json:"name"`
+	Field      string `json:"field"`
+	Type       string `json:"type"`
+	OmitEmpty  bool   `json:"omitempty"`
+	Unresolved bool   `json:"unresolved"`
+}
+
+// FieldInfo is a struct field. Embedded fields are named after their type.
+// DocString is the comment above the field, or failing that the one after it
+// on the same line.
+type FieldInfo struct {
+	Name      string            `json:"name"`
+	Type      string            `json:"type"`
+	Tag       string            `json:"tag"`
+	Tags      map[string]string `json:"tags"`
+	Embedded  bool              `json:"embedded"`
+	DocString string            `json:"docstring"`
+}
+
+type StructInfo struct {
+	Name        string      `json:"name"`
+	Exported    bool        `json:"exported"`
+	StartLine   int         `json:"start_line"`
+	EndLine     int         `json:"end_line"`
+	Fields      []FieldInfo `json:"fields"`
+	JSONFields  []JSONField `json:"json_fields"`
+	Annotations []string    `json:"annotations"`
+	IsAlias     bool        `json:"is_alias"`
+	DocString   string      `json:"docstring"`
+
+	// Implements lists the interfaces declared in the file that the struct
+	// satisfies with its value receiver methods. PointerImplements lists the
+	// ones only *T satisfies because they need pointer receiver methods.
+	Implements        []string `json:"implements"`
+	PointerImplements []string `json:"pointer_implements"`
+
+	// Layout is only filled in with LayoutArch
+	Layout *StructLayout `json:"layout,omitempty"`
+}
+
+// StructLayout is how a struct is laid out in memory on Arch, in bytes.
+// Padding is what the alignment of its fields wastes, and OptimalSize the
+// size it would have with the fields ordered by decreasing alignment.
+type StructLayout struct {
+	Arch        string        `json:"arch"`
+	Size        int64         `json:"size"`
+	Align       int64         `json:"align"`
+	Padding     int64         `json:"padding"`
+	OptimalSize int64         `json:"optimal_size"`
+	Fields      []FieldLayout `json:"fields"`
+}
+
+// FieldLayout is where a struct field sits, in bytes from the struct's start
+type FieldLayout struct {
+	Name   string `json:"name"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+	Align  int64  `json:"align"`
+}
+
+// MethodSignature is a method required by an interface
+type MethodSignature struct {
+	Name       string   `json:"name"`
+	Parameters []string `json:"parameters"`
+	Returns    string   `json:"returns"`
+	DocString  string   `json:"docstring"`
+}
+
+// InterfaceInfo is an interface declaration. Embedded lists embedded
+// interfaces and type constraints such as ~int | ~string.
+type InterfaceInfo struct {
+	Name        string            `json:"name"`
+	Exported    bool              `json:"exported"`
+	StartLine   int               `json:"start_line"`
+	EndLine     int               `json:"end_line"`
+	Methods     []MethodSignature `json:"methods"`
+	Embedded    []string          `json:"embedded"`
+	IsAlias     bool              `json:"is_alias"`
+	DocString   string            `json:"docstring"`
+	Annotations []string          `json:"annotations"`
+}
+
+// TypeInfo is a type declaration of any kind, with its members. Fields holds
+// a struct's fields, and Embedded an interface's embedded interfaces and
+// type constraints. Methods lists an interface's methods, or the value
+// receiver methods declared in the file for other types, and PointerMethods
+// the pointer receiver ones, which only *T's method set has.
+type TypeInfo struct {
+	Name        string   `json:"name"`
+	Exported    bool     `json:"exported"`
+	Kind        string   `json:"kind"`
+	Type        string   `json:"type"`
+	IsAlias     bool     `json:"is_alias"`
+	TypeParams  []string `json:"type_params"`
+	StartLine   int      `json:"start_line"`
+	EndLine     int      `json:"end_line"`
+	DocString   string   `json:"docstring"`
+	Annotations []string `json:"annotations"`
+
+	Fields         []FieldInfo       `json:"fields"`
+	Embedded       []string          `json:"embedded"`
+	Methods        []MethodSignature `json:"methods"`
+	PointerMethods []MethodSignature `json:"pointer_methods"`
+}
+
+// MultiFileInfo wraps the results for several files keyed by file name.
+// Packages groups those files by package. Errors holds the files that could
+// not be read or parsed, and SkippedFiles the generated files left out with
+// SkipGenerated.
+type MultiFileInfo struct {
+	SchemaVersion int                 `json:"schema_version"`
+	Files         map[string]FileInfo `json:"files"`
+	Packages      []PackageInfo       `json:"packages"`
+	Errors        map[string]string   `json:"errors"`
+	SkippedFiles  []string            `json:"skipped_files"`
+}
+
+// PackageInfo is a package as seen in the files of a MultiFileInfo: the
+// files in Dir whose package clause names it. Imports is the sorted union of
+// their imports. SingleCallerHelpers and EnumCoverage are worked out over all
+// of the files, so unlike the per-file lists they see declarations and calls
+// in the package's other files.
+type PackageInfo struct {
+	Name                string         `json:"name"`
+	Dir                 string         `json:"dir"`
+	Files               []string       `json:"files"`
+	Imports             []string       `json:"imports"`
+	FunctionCount       int            `json:"function_count"`
+	SingleCallerHelpers []string       `json:"single_caller_helpers"`
+	EnumCoverage        []EnumCoverage `json:"enum_coverage"`
+}
+
+// CallMatrix is a dense adjacency matrix of local calls. Matrix[i][j] is 1
+// when Functions[i] calls Functions[j].
+type CallMatrix struct {
+	SchemaVersion int      `json:"schema_version"`
+	Functions     []string `json:"functions"`
+	Matrix        [][]int  `json:"matrix"`
+}
+
+// TopoOrder lists functions with callees before callers. Order is only filled
+// in when the local call graph is acyclic; otherwise Cycles holds the strongly
+// connected components that prevent it.
+type TopoOrder struct {
+	SchemaVersion int        `json:"schema_version"`
+	Acyclic       bool       `json:"acyclic"`
+	Order         []string   `json:"order"`
+	Cycles        [][]string `json:"cycles"`
+}
+
+// Options controls what Parse extracts
+type Options struct {
+	// AnnotationPrefixes are the comment prefixes collected as annotations
+	AnnotationPrefixes []string
+	// ExportedOnly drops unexported functions, keeping init
+	ExportedOnly bool
+	// BuildTags selects files by their build constraints; nil disables it
+	BuildTags map[string]bool
+	// SkipGenerated leaves out files marked as generated code, which
+	// ParseTarget reports with ErrGenerated
+	SkipGenerated bool
+	// Filter, when set, keeps only the functions whose name matches it
+	Filter *regexp.Regexp
+	// Only keeps just "methods" or just "functions"; empty keeps both
+	Only string
+	// ErrorTypes are extra result types, such as xerrors.Error, that count as
+	// errors alongside the builtin error
+	ErrorTypes []string
+	// ResolveTypes type-checks each file's package to fill in the Resolved
+	// signature fields
+	ResolveTypes bool
+	// LayoutArch, when set, type-checks each file's package to fill in the
+	// Layout of its structs for that GOARCH
+	LayoutArch string
+	// FindUnused lists the unexported functions nothing else in the file
+	// refers to in UnusedFunctions
+	FindUnused bool
+	// Cache, when set, reuses results for files that haven't changed
+	Cache *Cache
+	// OnFile, when set, receives each parsed file of a directory or multi-file
+	// run as soon as it is done, instead of it being collected into Files.
+	// It is called from one goroutine at a time.
+	OnFile func(name string, info FileInfo)
+	// Jobs bounds how many files are parsed at once; below 1 means GOMAXPROCS
+	Jobs int
+}
+
+// DefaultOptions returns the options the CLI uses without any flags
+func DefaultOptions() Options {
+	return Options{AnnotationPrefixes: []string{"+", "@"}}
+}
+
+// calledName renders the function a call invokes: a bare or receiver
+// qualified name, followed by its type arguments when it explicitly
+// instantiates one of generics, as in Map[string,int]. It returns "" for
+// anything else, such as calling the result of another call or an element
+// of a slice or map of functions.
+func calledName(fun ast.Expr, generics map[string]bool) string {
+	switch f := fun.(type) {
+	case *ast.Ident:
+		return f.Name
+	case *ast.SelectorExpr:
+		// Keep the receiver so db.Query and cache.Query stay distinct
+		if recv, ok := f.X.(*ast.Ident); ok {
+			return recv.Name + "." + f.Sel.Name
+		}
+		return f.Sel.Name
+	case *ast.IndexExpr:
+		if name, ok := f.X.(*ast.Ident); ok && generics[name.Name] {
+			return name.Name + "[" + types.ExprString(f.Index) + "]"
+		}
+	case *ast.IndexListExpr:
+		if name, ok := f.X.(*ast.Ident); ok && generics[name.Name] {
+			args := make([]string, len(f.Indices))
+			for i, index := range f.Indices {
+				args[i] = types.ExprString(index)
+			}
+			return name.Name + "[" + strings.Join(args, ",") + "]"
+		}
+	}
+	return ""
+}
+
+// collectGenericFuncs returns the names of the generic functions declared in
+// the file
+func collectGenericFuncs(file *ast.File) map[string]bool {
+	generics := make(map[string]bool)
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Type.TypeParams != nil {
+			generics[fn.Name.Name] = true
+		}
+	}
+	return generics
+}
+
+// ExtractFunctionCalls returns function calls inside the node, deduplicated
+// and in order of first occurrence so the output is stable between runs.
+// Explicit instantiations are only recognized when node is a whole file,
+// since otherwise the generic functions it declares aren't known.
+func ExtractFunctionCalls(node ast.Node) []string {
+	var generics map[string]bool
+	if file, ok := node.(*ast.File); ok {
+		generics = collectGenericFuncs(file)
+	}
+	return extractCalls(node, generics)
+}
+
+// extractCalls returns the calls inside the node as ExtractFunctionCalls
+// does, with generics naming the file's generic functions
+func extractCalls(node ast.Node, generics map[string]bool) []string {
+	seen := make(map[string]bool)
+	result := []string{}
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			result = append(result, name)
+		}
+	}
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.CallExpr:
+			if name := calledName(x.Fun, generics); name != "" {
+				add(name)
+			}
+		}
+		return true
+	})
+	return result
+}
+
+// extractExternalCalls returns the entries of Calls that call into an
+// imported package, such as errors.New, in order of first occurrence. As in
+// extractUsedImports, a qualifier the parser resolved to a local declaration
+// isn't a package.
+func extractExternalCalls(fn *ast.FuncDecl, importNames map[string]string) []string {
+	seen := make(map[string]bool)
+	calls := []string{}
+	ast.Inspect(fn, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Obj == nil {
+			if _, ok := importNames[pkg.Name]; ok {
+				if name := calledName(sel, nil); !seen[name] {
+					seen[name] = true
+					calls = append(calls, name)
+				}
+			}
+		}
+		return true
+	})
+	return calls
+}
+
+// localCalls returns the calls of fn that may refer to functions in the same
+// file, leaving out those into imported packages so errors.New isn't taken
+// for a local New
+func localCalls(fn FunctionInfo) []string {
+	if len(fn.ExternalCalls) == 0 {
+		return fn.Calls
+	}
+	external := make(map[string]bool, len(fn.ExternalCalls))
+	for _, call := range fn.ExternalCalls {
+		external[call] = true
+	}
+	var calls []string
+	for _, call := range fn.Calls {
+		if !external[call] {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// callName returns the called function or method name of an entry in Calls,
+// dropping any receiver qualifier and type arguments
+func callName(call string) string {
+	call = stripTypeArgs(call)
+	return call[strings.LastIndex(call, ".")+1:]
+}
+
+// stripTypeArgs drops the type arguments of an instantiated call in Calls,
+// so Map[string,int] becomes Map
+func stripTypeArgs(call string) string {
+	if i := strings.Index(call, "["); i >= 0 {
+		return call[:i]
+	}
+	return call
+}
+
+// extractParameters returns the parameter types
+func extractParameters(params *ast.FieldList) []string {
+	return typeRenderer{}.params(params)
+}
+
+// collectUnknownTypes renders every type expression in the file, in
+// parameter and result lists, struct fields, type and value declarations,
+// and returns the ones that could only be rendered as "unknown", in source
+// order, so -strict can point at the gaps in type rendering
+func collectUnknownTypes(file *ast.File, fSet *token.FileSet) []string {
+	seen := make(map[token.Pos]bool)
+	var found []ast.Expr
+	r := typeRenderer{unknown: func(expr ast.Expr) {
+		if !seen[expr.Pos()] {
+			seen[expr.Pos()] = true
+			found = append(found, expr)
+		}
+	}}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.Field:
+			r.render(x.Type)
+		case *ast.TypeSpec:
+			r.render(x.Type)
+		case *ast.ValueSpec:
+			if x.Type != nil {
+				r.render(x.Type)
+			}
+		}
+		return true
+	})
+
+	sort.Slice(found, func(i, j int) bool { return found[i].Pos() < found[j].Pos() })
+	unknown := []string{}
+	for _, expr := range found {
+		unknown = append(unknown, fmt.Sprintf("%s: cannot render type %s (%T)", fSet.Position(expr.Pos()), nodeString(fSet, expr), expr))
+	}
+	return unknown
+}
+
+// ReportUnknownTypes writes the unknown type renderings of files to w and
+// returns how many there were
+func ReportUnknownTypes(w io.Writer, files []FileInfo) int {
+	count := 0
+	for _, info := range files {
+		for _, msg := range info.UnknownTypes {
+			fmt.Fprintln(w, msg)
+			count++
+		}
+	}
+	return count
+}
+
+// ExtractTypeString converts an ast.Expr representing a type to its string repr
+func ExtractTypeString(expr ast.Expr) string {
+	return typeRenderer{}.render(expr)
+}
+
+// typeRenderer renders type expressions. When unknown is set it is called
+// with each expression that could only be rendered as "unknown".
+type typeRenderer struct {
+	unknown func(ast.Expr)
+}
+
+// fail reports expr as unrenderable and returns its placeholder
+func (r typeRenderer) fail(expr ast.Expr) string {
+	if r.unknown != nil {
+		r.unknown(expr)
+	}
+	return "unknown"
+}
+
+// params renders the types of a parameter or result list, one per name
+func (r typeRenderer) params(params *ast.FieldList) []string {
+	if params == nil {
+		return []string{}
+	}
+
+	var result []string
+	for _, param := range params.List {
+		paramType := r.render(param.Type)
+		for i := 0; i < max(1, len(param.Names)); i++ {
+			result = append(result, paramType)
+		}
+	}
+	return result
+}
+
+// render converts a type expression to its string repr
+func (r typeRenderer) render(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+
+	case *ast.StarExpr:
+		return "*" + r.render(t.X)
+
+	case *ast.ArrayType:
+		if t.Len == nil {
+			// Slice
+			return "[]" + r.render(t.Elt)
+		}
+		if _, ok := t.Len.(*ast.Ellipsis); ok {
+			return "[...]" + r.render(t.Elt)
+		}
+		// Array -- the length may be a literal, a named constant or an expression
+		return "[" + types.ExprString(t.Len) + "]" + r.render(t.Elt)
+
+	case *ast.MapType:
+		return "map[" + r.render(t.Key) + "]" + r.render(t.Value)
+
+	case *ast.ChanType:
+		switch t.Dir {
+		case ast.SEND:
+			return "chan<- " + r.render(t.Value)
+		case ast.RECV:
+			return "<-chan " + r.render(t.Value)
+		default:
+			return "chan " + r.render(t.Value)
+		}
+
+	case *ast.FuncType:
+		signature := "func(" + strings.Join(r.params(t.Params), ", ") + ")"
+		if results := r.params(t.Results); len(results) > 0 {
+			signature += " (" + strings.Join(results, ", ") + ")"
+		}
+		return signature
+
+	case *ast.InterfaceType:
+		// Methods and embedded elements, on one line like go/types prints them
+		elems := make([]string, 0, len(t.Methods.List))
+		for _, field := range t.Methods.List {
+			fnType, isMethod := field.Type.(*ast.FuncType)
+			if !isMethod || len(field.Names) == 0 {
+				elems = append(elems, r.render(field.Type))
+				continue
+			}
+			signature := strings.TrimPrefix(r.render(fnType), "func")
+			for _, name := range field.Names {
+				elems = append(elems, name.Name+signature)
+			}
+		}
+		return "interface{" + strings.Join(elems, "; ") + "}"
+
+	case *ast.StructType:
+		fields := make([]string, 0, len(t.Fields.List))
+		for _, field := range t.Fields.List {
+			names := make([]string, len(field.Names))
+			for i, name := range field.Names {
+				names[i] = name.Name
+			}
+			rendered := r.render(field.Type)
+			if len(names) > 0 {
+				rendered = strings.Join(names, ", ") + " " + rendered
+			}
+			if field.Tag != nil {
+				rendered += " " + field.Tag.Value
+			}
+			fields = append(fields, rendered)
+		}
+		return "struct{" + strings.Join(fields, "; ") + "}"
+
+	case *ast.SelectorExpr:
+		// Recurse so chains of any depth like a.b.Type render in full
+		return r.render(t.X) + "." + t.Sel.Name
+
+	case *ast.Ellipsis:
+		return "..." + r.render(t.Elt)
+
+	case *ast.IndexExpr:
+		// Generic instantiation with one type argument, e.g. Stack[T]
+		return r.render(t.X) + "[" + r.render(t.Index) + "]"
+
+	case *ast.IndexListExpr:
+		args := make([]string, 0, len(t.Indices))
+		for _, index := range t.Indices {
+			args = append(args, r.render(index))
+		}
+		return r.render(t.X) + "[" + strings.Join(args, ", ") + "]"
+
+	case *ast.UnaryExpr:
+		// Approximation element in a constraint, e.g. ~int
+		if t.Op == token.TILDE {
+			return "~" + r.render(t.X)
+		}
+		return r.fail(expr)
+
+	case *ast.BinaryExpr:
+		// Union in a constraint, e.g. ~int | ~string
+		if t.Op == token.OR {
+			return r.render(t.X) + " | " + r.render(t.Y)
+		}
+		return r.fail(expr)
+
+	case *ast.ParenExpr:
+		return "(" + r.render(t.X) + ")"
+
+	default:
+		return r.fail(expr)
+	}
+}
+
+// extractTypeParams renders type parameters as "name constraint" entries
+func extractTypeParams(params *ast.FieldList) []string {
+	result := []string{}
+	if params == nil {
+		return result
+	}
+
+	for _, param := range params.List {
+		constraint := ExtractTypeString(param.Type)
+		for _, name := range param.Names {
+			result = append(result, name.Name+" "+constraint)
+		}
+	}
+	return result
+}
+
+// extractReturnTypes returns return types
+func extractReturnTypes(results *ast.FieldList) string {
+	if results == nil {
+		return ""
+	}
+
+	var types []string
+	for _, result := range results.List {
+		resultType := ExtractTypeString(result.Type)
+
+		// Named results like (a, b int) declare one result per name
+		for i := 0; i < max(1, len(result.Names)); i++ {
+			types = append(types, resultType)
+		}
+	}
+	return strings.Join(types, ", ")
+}
+
+// extractFieldNames returns the name of each parameter or result, in the same
+// order as extractParameters and extractReturnTypes list their types.
+// Anonymous ones have an empty name.
+func extractFieldNames(fields *ast.FieldList) []string {
+	names := []string{}
+	if fields == nil {
+		return names
+	}
+
+	for _, field := range fields.List {
+		if len(field.Names) == 0 {
+			names = append(names, "")
+			continue
+		}
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+	}
+	return names
+}
+
+// countSignatureTypes returns the number of distinct types across a function's
+// parameters and results
+func countSignatureTypes(fnType *ast.FuncType) int {
+	types := make(map[string]bool)
+	for _, fields := range []*ast.FieldList{fnType.Params, fnType.Results} {
+		if fields == nil {
+			continue
+		}
+		for _, field := range fields.List {
+			types[ExtractTypeString(field.Type)] = true
+		}
+	}
+	return len(types)
+}
+
+// extractDocstring returns the docstring cleaned up a bit
+func extractDocstring(cg *ast.CommentGroup) string {
+	if cg == nil {
+		return ""
+	}
+
+	var lines []string
+	for _, comment := range cg.List {
+		// Compiler directives are reported separately by extractPragmas
+		if strings.HasPrefix(comment.Text, "//go:") {
+			continue
+		}
+		line := strings.TrimPrefix(comment.Text, "//")
+		line = strings.TrimSpace(line)
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, " ")
+}
+
+// extractRawDocstring returns the doc comment with its line breaks intact, so
+// paragraphs, lists and code blocks survive. Only the comment markers and one
+// space after // are stripped.
+func extractRawDocstring(cg *ast.CommentGroup) string {
+	if cg == nil {
+		return ""
+	}
+
+	var lines []string
+	for _, comment := range cg.List {
+		if strings.HasPrefix(comment.Text, "//go:") {
+			continue
+		}
+		if text, ok := strings.CutPrefix(comment.Text, "/*"); ok {
+			lines = append(lines, strings.Split(strings.TrimSuffix(text, "*/"), "\n")...)
+			continue
+		}
+		line := strings.TrimPrefix(comment.Text, "//")
+		lines = append(lines, strings.TrimPrefix(line, " "))
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// extractDocBlocks splits the doc comment into text and code blocks the way
+// go/doc/comment reads it, so code examples can be told apart from prose
+func extractDocBlocks(cg *ast.CommentGroup) []DocBlock {
+	blocks := []DocBlock{}
+	raw := extractRawDocstring(cg)
+	if raw == "" {
+		return blocks
+	}
+
+	var parser comment.Parser
+	for _, block := range parser.Parse(raw).Content {
+		switch b := block.(type) {
+		case *comment.Code:
+			blocks = append(blocks, DocBlock{Kind: "code", Text: strings.TrimSuffix(b.Text, "\n")})
+		case *comment.Paragraph:
+			blocks = append(blocks, DocBlock{Kind: "text", Text: docText(b.Text)})
+		case *comment.Heading:
+			blocks = append(blocks, DocBlock{Kind: "text", Text: docText(b.Text)})
+		case *comment.List:
+			items := make([]string, 0, len(b.Items))
+			for _, item := range b.Items {
+				marker := "-"
+				if item.Number != "" {
+					marker = item.Number + "."
+				}
+				var paragraphs []string
+				for _, content := range item.Content {
+					if p, ok := content.(*comment.Paragraph); ok {
+						paragraphs = append(paragraphs, docText(p.Text))
+					}
+				}
+				items = append(items, marker+" "+strings.Join(paragraphs, " "))
+			}
+			blocks = append(blocks, DocBlock{Kind: "text", Text: strings.Join(items, "\n")})
+		}
+	}
+	return blocks
+}
+
+// docText flattens the inline text of a doc comment block, keeping the words
+// of links and doc links
+func docText(text []comment.Text) string {
+	var b strings.Builder
+	for _, t := range text {
+		switch t := t.(type) {
+		case comment.Plain:
+			b.WriteString(string(t))
+		case comment.Italic:
+			b.WriteString(string(t))
+		case *comment.Link:
+			b.WriteString(docText(t.Text))
+		case *comment.DocLink:
+			b.WriteString(docText(t.Text))
+		}
+	}
+	return b.String()
+}
+
+// detectErrorResults reports whether any result is an error, meaning the
+// builtin error or one of errorTypes, and whether the last one is, as in the
+// usual (T, error) shape
+func detectErrorResults(results *ast.FieldList, errorTypes []string) (returnsError bool, errorIsLast bool) {
+	if results == nil {
+		return false, false
+	}
+
+	isError := func(expr ast.Expr) bool {
+		name := ExtractTypeString(expr)
+		if name == "error" {
+			return true
+		}
+		for _, errorType := range errorTypes {
+			if name == errorType {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i, field := range results.List {
+		if isError(field.Type) {
+			returnsError = true
+			errorIsLast = i == len(results.List)-1
+		}
+	}
+	return returnsError, errorIsLast
+}
+
+// detectContextParams reports whether any parameter is a context.Context,
+// under whatever name context is imported as, and whether the first one is.
+// A bare Context counts when context is dot-imported.
+func detectContextParams(params *ast.FieldList, importNames map[string]string, dotImported bool) (takesContext bool, contextIsFirst bool) {
+	if params == nil {
+		return false, false
+	}
+
+	isContext := func(expr ast.Expr) bool {
+		switch t := expr.(type) {
+		case *ast.SelectorExpr:
+			pkg, ok := t.X.(*ast.Ident)
+			return ok && t.Sel.Name == "Context" && importNames[pkg.Name] == "context"
+		case *ast.Ident:
+			return dotImported && t.Name == "Context"
+		}
+		return false
+	}
+
+	for i, field := range params.List {
+		if isContext(field.Type) {
+			takesContext = true
+			contextIsFirst = contextIsFirst || i == 0
+		}
+	}
+	return takesContext, contextIsFirst
+}
+
+// extractDeprecation looks for a paragraph starting with "Deprecated:" in the
+// doc comment, the convention godoc recognizes, and returns the rest of that
+// paragraph joined onto one line. It works on the comment's lines because
+// extractDocstring flattens away the paragraph breaks.
+func extractDeprecation(cg *ast.CommentGroup) (bool, string) {
+	if cg == nil {
+		return false, ""
+	}
+
+	lines := strings.Split(cg.Text(), "\n")
+	for i, line := range lines {
+		// Only the start of a paragraph counts
+		if i > 0 && strings.TrimSpace(lines[i-1]) != "" {
+			continue
+		}
+		rest, ok := strings.CutPrefix(line, "Deprecated:")
+		if !ok {
+			continue
+		}
+
+		note := []string{}
+		if rest = strings.TrimSpace(rest); rest != "" {
+			note = append(note, rest)
+		}
+		for _, next := range lines[i+1:] {
+			if next = strings.TrimSpace(next); next == "" {
+				break
+			}
+			note = append(note, next)
+		}
+		return true, strings.Join(note, " ")
+	}
+	return false, ""
+}
+
+// extractPragmas returns the //go: compiler directives in the doc comment
+func extractPragmas(cg *ast.CommentGroup) []string {
+	pragmas := []string{}
+	if cg == nil {
+		return pragmas
+	}
+
+	for _, comment := range cg.List {
+		if strings.HasPrefix(comment.Text, "//go:") {
+			pragmas = append(pragmas, strings.TrimSpace(comment.Text))
+		}
+	}
+	return pragmas
+}
+
+// extractAnnotations returns the doc comment lines that start with one of the
+// prefixes, such as +kubebuilder:object:root=true or @Summary
+func extractAnnotations(cg *ast.CommentGroup, prefixes []string) []string {
+	annotations := []string{}
+	if cg == nil {
+		return annotations
+	}
+
+	for _, comment := range cg.List {
+		line := strings.TrimSpace(strings.TrimPrefix(comment.Text, "//"))
+		for _, prefix := range prefixes {
+			if prefix != "" && strings.HasPrefix(line, prefix) {
+				annotations = append(annotations, line)
+				break
+			}
+		}
+	}
+	return annotations
+}
+
+// closureName returns the variable a function literal is assigned to, from
+// `v := func...`, `v = func...` or `var v = func...`, or "" when it is
+// passed or called inline
+func closureName(lit *ast.FuncLit, parent ast.Node) string {
+	switch p := parent.(type) {
+	case *ast.AssignStmt:
+		for i, rhs := range p.Rhs {
+			if rhs == lit && i < len(p.Lhs) && len(p.Lhs) == len(p.Rhs) {
+				return types.ExprString(p.Lhs[i])
+			}
+		}
+	case *ast.ValueSpec:
+		for i, value := range p.Values {
+			if value == lit && i < len(p.Names) {
+				return p.Names[i].Name
+			}
+		}
+	}
+	return ""
+}
+
+// extractClosures returns every function literal in the file. Path lists the
+// functions enclosing each one from the outside in, naming a closure by its
+// variable or, failing that, func@line, so nested closures can be told apart.
+func extractClosures(file *ast.File, fSet *token.FileSet) []ClosureInfo {
+	closures := []ClosureInfo{}
+	var nodes []ast.Node
+	var path []string
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n == nil {
+			switch nodes[len(nodes)-1].(type) {
+			case *ast.FuncDecl, *ast.FuncLit:
+				path = path[:len(path)-1]
+			}
+			nodes = nodes[:len(nodes)-1]
+			return true
+		}
+
+		switch x := n.(type) {
+		case *ast.FuncDecl:
+			name := x.Name.Name
+			if x.Recv != nil && len(x.Recv.List) > 0 {
+				if typeName := embeddedTypeName(x.Recv.List[0].Type); typeName != "" {
+					name = typeName + "." + name
+				}
+			}
+			path = append(path, name)
+
+		case *ast.FuncLit:
+			var parent ast.Node
+			if len(nodes) > 0 {
+				parent = nodes[len(nodes)-1]
+			}
+			startLine := fSet.Position(x.Pos()).Line
+			info := ClosureInfo{
+				Name:       closureName(x, parent),
+				Parameters: extractParameters(x.Type.Params),
+				Returns:    extractReturnTypes(x.Type.Results),
+				StartLine:  startLine,
+				EndLine:    fSet.Position(x.End()).Line,
+				Path:       append([]string{}, path...),
+			}
+			if len(path) > 0 {
+				info.EnclosingFunction = path[0]
+			}
+			closures = append(closures, info)
+
+			label := info.Name
+			if label == "" {
+				label = fmt.Sprintf("func@%d", startLine)
+			}
+			path = append(path, label)
+		}
+		nodes = append(nodes, n)
+		return true
+	})
+	return closures
+}
+
+// summarizeComplexity totals and averages the functions' complexity and
+// finds the most complex one
+func summarizeComplexity(functions []FunctionInfo) Summary {
+	var summary Summary
+	for _, fn := range functions {
+		summary.TotalComplexity += fn.Complexity
+		if fn.Complexity > summary.MaxComplexity {
+			summary.MaxComplexity = fn.Complexity
+			summary.MostComplexFunction = dotNodeName(fn)
+		}
+	}
+	if len(functions) > 0 {
+		summary.AverageComplexity = float64(summary.TotalComplexity) / float64(len(functions))
+	}
+	return summary
+}
+
+// computeMetrics counts the file's lines by kind. A line holding only
+// comments is a comment line, one with any code is a code line even if it
+// also has a trailing comment, and whitespace-only lines are blank.
+func computeMetrics(file *ast.File, fSet *token.FileSet, sourceLines []string) Metrics {
+	// A final newline doesn't start another line
+	if n := len(sourceLines); n > 0 && sourceLines[n-1] == "" {
+		sourceLines = sourceLines[:n-1]
+	}
+
+	// Blank out the comment text so whatever is left on a line is code
+	stripped := make(map[int][]byte)
+	for _, cg := range file.Comments {
+		for _, comment := range cg.List {
+			start, end := fSet.Position(comment.Pos()), fSet.Position(comment.End())
+			for line := start.Line; line <= end.Line && line <= len(sourceLines); line++ {
+				text, ok := stripped[line]
+				if !ok {
+					text = []byte(sourceLines[line-1])
+				}
+				from, to := 0, len(text)
+				if line == start.Line {
+					from = start.Column - 1
+				}
+				if line == end.Line {
+					to = min(end.Column-1, len(text))
+				}
+				for i := from; i < to; i++ {
+					text[i] = ' '
+				}
+				stripped[line] = text
+			}
+		}
+	}
+
+	metrics := Metrics{TotalLines: len(sourceLines)}
+	for i, line := range sourceLines {
+		switch text, commented := stripped[i+1]; {
+		case strings.TrimSpace(line) == "":
+			metrics.BlankLines++
+		case commented && strings.TrimSpace(string(text)) == "":
+			metrics.CommentLines++
+		default:
+			metrics.CodeLines++
+		}
+	}
+	return metrics
+}
+
+// markerPattern matches a TODO-style tag at the start of a comment line,
+// case-insensitively and with an optional colon after the tag
+var markerPattern = regexp.MustCompile(`(?i)^(TODO|FIXME|HACK|XXX)\b:?\s*(.*)$`)
+
+// extractMarkers returns the TODO, FIXME, HACK and XXX markers in every
+// comment of the file, not only doc comments. Block comments are checked
+// line by line.
+func extractMarkers(file *ast.File, fSet *token.FileSet) []Marker {
+	markers := []Marker{}
+	for _, cg := range file.Comments {
+		for _, comment := range cg.List {
+			line := fSet.Position(comment.Slash).Line
+			var texts []string
+			if strings.HasPrefix(comment.Text, "//") {
+				texts = []string{strings.TrimPrefix(comment.Text, "//")}
+			} else {
+				texts = strings.Split(strings.TrimSuffix(strings.TrimPrefix(comment.Text, "/*"), "*/"), "\n")
+			}
+
+			for i, text := range texts {
+				text = strings.TrimSpace(text)
+				text = strings.TrimSpace(strings.TrimPrefix(text, "*"))
+				match := markerPattern.FindStringSubmatch(text)
+				if match == nil {
+					continue
+				}
+				markers = append(markers, Marker{
+					Tag:  strings.ToUpper(match[1]),
+					Text: strings.TrimSpace(match[2]),
+					Line: line + i,
+				})
+			}
+		}
+	}
+	return markers
+}
+
+// typeSpecDoc returns the doc comment of a type spec. For an ungrouped
+// declaration like `type T struct{}` the comment belongs to the GenDecl.
+func typeSpecDoc(genDecl *ast.GenDecl, typeSpec *ast.TypeSpec) *ast.CommentGroup {
+	if typeSpec.Doc != nil {
+		return typeSpec.Doc
+	}
+	if !genDecl.Lparen.IsValid() {
+		return genDecl.Doc
+	}
+	return nil
+}
+
+// valueSpecDoc returns the doc comment of a const or var spec, falling back
+// to the comment after it on the same line. As with typeSpecDoc, the doc of
+// an ungrouped declaration belongs to the GenDecl.
+func valueSpecDoc(genDecl *ast.GenDecl, valueSpec *ast.ValueSpec) string {
+	doc := valueSpec.Doc
+	if doc == nil && !genDecl.Lparen.IsValid() {
+		doc = genDecl.Doc
+	}
+	if doc == nil {
+		doc = valueSpec.Comment
+	}
+	return extractDocstring(doc)
+}
+
+// hashBody returns the SHA-256 hex digest of the body's AST shape: every node
+// type along with its names, literals and operators, but no positions or
+// comments, so reformatting or recommenting leaves the hash unchanged.
+// Declarations without a body hash to "".
+func hashBody(body *ast.BlockStmt) string {
+	if body == nil {
+		return ""
+	}
+
+	h := sha256.New()
+	ast.Inspect(body, func(n ast.Node) bool {
+		if n == nil {
+			io.WriteString(h, ")")
+			return true
+		}
+		v := reflect.ValueOf(n).Elem()
+		fmt.Fprintf(h, "(%s", v.Type().Name())
+		for i := 0; i < v.NumField(); i++ {
+			switch f := v.Field(i); f.Interface().(type) {
+			case token.Token, ast.ChanDir, string, bool:
+				fmt.Fprintf(h, " %v", f.Interface())
+			}
+		}
+		return true
+	})
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// calculateNestingDepth returns how deeply block statements nest in the
+// function. The body itself is depth 1 and each if, for, switch, select or
+// function literal goes one deeper; an else if stays at the depth of its if.
+func calculateNestingDepth(fn *ast.FuncDecl) int {
+	maxDepth := 1
+	if fn.Body == nil {
+		return maxDepth
+	}
+
+	var visit func(n ast.Node, depth int)
+	var visitIf func(s *ast.IfStmt, depth int)
+	visit = func(n ast.Node, depth int) {
+		if n == nil {
+			return
+		}
+		maxDepth = max(maxDepth, depth)
+		ast.Inspect(n, func(c ast.Node) bool {
+			if c == n {
+				return true
+			}
+			switch x := c.(type) {
+			case *ast.IfStmt:
+				visitIf(x, depth+1)
+				return false
+			case *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt, *ast.FuncLit:
+				visit(x, depth+1)
+				return false
+			}
+			return true
+		})
+	}
+	visitIf = func(s *ast.IfStmt, depth int) {
+		maxDepth = max(maxDepth, depth)
+		visit(s.Init, depth)
+		visit(s.Cond, depth)
+		visit(s.Body, depth)
+		if elseIf, ok := s.Else.(*ast.IfStmt); ok {
+			visitIf(elseIf, depth)
+		} else {
+			visit(s.Else, depth)
+		}
+	}
+
+	visit(fn.Body, 1)
+	return maxDepth
+}
+
+// countStatements returns the number of statements in the function body,
+// those in function literals included. Every ast.Stmt counts except block
+// statements, which only group others and include the body itself, and empty
+// statements. An if, for, switch or select counts once on its own, a case or
+// select clause once, and a labeled statement once on top of the one it
+// labels; a statement in an if or for header counts like any other.
+func countStatements(fn *ast.FuncDecl) int {
+	count := 0
+	if fn.Body == nil {
+		return count
+	}
+
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.BlockStmt, *ast.EmptyStmt:
+		case ast.Stmt:
+			count++
+		}
+		return true
+	})
+	return count
+}
+
+// calculateComplexity returns the McCabe cyclomatic complexity of the function:
+// 1 plus one for every branch point and short-circuit operator in the body
+func calculateComplexity(fn *ast.FuncDecl) int {
+	complexity := 1
+	if fn.Body == nil {
+		return complexity
+	}
+
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt, *ast.CaseClause, *ast.CommClause:
+			complexity++
+		case *ast.BinaryExpr:
+			if x.Op == token.LAND || x.Op == token.LOR {
+				complexity++
+			}
+		}
+		return true
+	})
+	return complexity
+}
+
+// collectStringIdents returns the names of identifiers in the function that are
+// likely strings: string params, `var s string` declarations and `s := "..."`
+func collectStringIdents(fn *ast.FuncDecl) map[string]bool {
+	idents := make(map[string]bool)
+
+	if fn.Type.Params != nil {
+		for _, param := range fn.Type.Params.List {
+			if ExtractTypeString(param.Type) != "string" {
+				continue
+			}
+			for _, name := range param.Names {
+				idents[name.Name] = true
+			}
+		}
+	}
+
+	ast.Inspect(fn, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.ValueSpec:
+			if x.Type != nil && ExtractTypeString(x.Type) == "string" {
+				for _, name := range x.Names {
+					idents[name.Name] = true
+				}
+			}
+		case *ast.AssignStmt:
+			if x.Tok != token.DEFINE || len(x.Lhs) != len(x.Rhs) {
+				return true
+			}
+			for i, rhs := range x.Rhs {
+				if lit, ok := rhs.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+					if ident, ok := x.Lhs[i].(*ast.Ident); ok {
+						idents[ident.Name] = true
+					}
+				}
+			}
+		}
+		return true
+	})
+	return idents
+}
+
+// isStringExpr makes a best-effort guess at whether expr evaluates to a string
+func isStringExpr(expr ast.Expr, stringIdents map[string]bool) bool {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		return e.Kind == token.STRING
+	case *ast.Ident:
+		return stringIdents[e.Name]
+	case *ast.ParenExpr:
+		return isStringExpr(e.X, stringIdents)
+	case *ast.BinaryExpr:
+		return e.Op == token.ADD && (isStringExpr(e.X, stringIdents) || isStringExpr(e.Y, stringIdents))
+	case *ast.CallExpr:
+		if ident, ok := e.Fun.(*ast.Ident); ok && ident.Name == "string" {
+			return true
+		}
+		if sel, ok := e.Fun.(*ast.SelectorExpr); ok {
+			if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Name == "fmt" && sel.Sel.Name == "Sprintf" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// detectStringConcatInLoop reports whether a string is built up with + or +=
+// inside a for/range loop, which is quadratic compared to strings.Builder
+func detectStringConcatInLoop(fn *ast.FuncDecl) bool {
+	if fn.Body == nil {
+		return false
+	}
+
+	stringIdents := collectStringIdents(fn)
+	found := false
+
+	inspectLoop := func(body *ast.BlockStmt) {
+		ast.Inspect(body, func(n ast.Node) bool {
+			assign, ok := n.(*ast.AssignStmt)
+			if !ok || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+				return !found
+			}
+			switch assign.Tok {
+			case token.ADD_ASSIGN:
+				if isStringExpr(assign.Lhs[0], stringIdents) || isStringExpr(assign.Rhs[0], stringIdents) {
+					found = true
+				}
+			case token.ASSIGN:
+				if bin, ok := assign.Rhs[0].(*ast.BinaryExpr); ok && bin.Op == token.ADD && isStringExpr(bin, stringIdents) {
+					found = true
+				}
+			}
+			return !found
+		})
+	}
+
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		if found {
+			return false
+		}
+		switch x := n.(type) {
+		case *ast.ForStmt:
+			inspectLoop(x.Body)
+		case *ast.RangeStmt:
+			inspectLoop(x.Body)
+		}
+		return !found
+	})
+	return found
+}
+
+// callerKey identifies fn in the local call graph: its name, or Type.Name for
+// a method, so a method and a function sharing a name are kept apart
+func callerKey(fn FunctionInfo) string {
+	if fn.IsMethod {
+		return receiverTypeName(fn.Receiver) + "." + fn.Name
+	}
+	return fn.Name
+}
+
+// localCallers maps each function defined in functions to the distinct
+// functions (other than itself) that call it, all keyed by callerKey. An
+// unqualified call can only be to a function, while one like x.load is taken
+// to be to every method of that name since the type of x isn't known.
+func localCallers(functions []FunctionInfo) map[string][]string {
+	callers := make(map[string][]string)
+	methods := make(map[string][]string)
+	for _, fn := range functions {
+		key := callerKey(fn)
+		if _, ok := callers[key]; ok {
+			continue
+		}
+		callers[key] = nil
+		if fn.IsMethod {
+			methods[fn.Name] = append(methods[fn.Name], key)
+		}
+	}
+
+	for _, fn := range functions {
+		caller := callerKey(fn)
+		for _, call := range localCalls(fn) {
+			call = stripTypeArgs(call)
+			callees := []string{call}
+			if i := strings.LastIndex(call, "."); i >= 0 {
+				callees = methods[call[i+1:]]
+			}
+			for _, callee := range callees {
+				if _, ok := callers[callee]; !ok || callee == caller {
+					continue
+				}
+				seen := false
+				for _, existing := range callers[callee] {
+					if existing == caller {
+						seen = true
+						break
+					}
+				}
+				if !seen {
+					callers[callee] = append(callers[callee], caller)
+				}
+			}
+		}
+	}
+	return callers
+}
+
+// findSingleCallerHelpers returns the unexported functions, not methods, that
+// have exactly one local caller, which makes them candidates for inlining or
+// consolidation
+func findSingleCallerHelpers(functions []FunctionInfo) []string {
+	helpers := []string{}
+	for key, callers := range localCallers(functions) {
+		if len(callers) == 1 && !strings.Contains(key, ".") && !ast.IsExported(key) {
+			helpers = append(helpers, key)
+		}
+	}
+	sort.Strings(helpers)
+	return helpers
+}
+
+// unusedNote qualifies UnusedFunctions in the output
+const unusedNote = "heuristic: only references within this file are seen, so functions used from other files of the package are listed too"
+
+// findUnusedFunctions returns the sorted unexported free functions that
+// nothing else in the file refers to, whether by calling them or using them
+// as a value. Calls from within a function's own body don't count. init,
+// main, blank functions and ones with a //go:linkname directive are never
+// listed. Methods are left out as they may satisfy an interface.
+func findUnusedFunctions(file *ast.File) []string {
+	candidates := make(map[string]bool)
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || fn.Name.IsExported() {
+			continue
+		}
+		switch fn.Name.Name {
+		case "init", "main", "_":
+			continue
+		}
+		linkname := false
+		for _, pragma := range extractPragmas(fn.Doc) {
+			linkname = linkname || strings.HasPrefix(pragma, "//go:linkname")
+		}
+		if !linkname {
+			candidates[fn.Name.Name] = true
+		}
+	}
+
+	referenced := make(map[string]bool)
+	// collect records the identifiers under node other than self. The Sel of
+	// x.name never refers to a local function, so only x is looked at.
+	var collect func(node ast.Node, self string)
+	collect = func(node ast.Node, self string) {
+		ast.Inspect(node, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.SelectorExpr:
+				collect(x.X, self)
+				return false
+			case *ast.Ident:
+				if x.Name != self {
+					referenced[x.Name] = true
+				}
+			}
+			return true
+		})
+	}
+
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			collect(decl, "")
+			continue
+		}
+		// Leave out the declared name, and for a function its own body
+		self := ""
+		if fn.Recv == nil {
+			self = fn.Name.Name
+		} else {
+			collect(fn.Recv, "")
+		}
+		collect(fn.Type, self)
+		if fn.Body != nil {
+			collect(fn.Body, self)
+		}
+	}
+
+	unused := []string{}
+	for name := range candidates {
+		if !referenced[name] {
+			unused = append(unused, name)
+		}
+	}
+	sort.Strings(unused)
+	return unused
+}
+
+// isRecoverCall reports whether expr is a call to the builtin recover
+func isRecoverCall(expr ast.Expr) bool {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	ident, ok := call.Fun.(*ast.Ident)
+	return ok && ident.Name == "recover"
+}
+
+// detectRecoverIgnoresValue reports whether a deferred recover() swallows the
+// panic value by discarding it or assigning it to _
+func detectRecoverIgnoresValue(fn *ast.FuncDecl) bool {
+	if fn.Body == nil {
+		return false
+	}
+
+	ignored := false
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		deferStmt, ok := n.(*ast.DeferStmt)
+		if !ok {
+			return !ignored
+		}
+
+		// defer recover() never sees the value at all
+		if isRecoverCall(deferStmt.Call) {
+			ignored = true
+			return false
+		}
+
+		lit, ok := deferStmt.Call.Fun.(*ast.FuncLit)
+		if !ok {
+			return true
+		}
+		ast.Inspect(lit.Body, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.ExprStmt:
+				if isRecoverCall(x.X) {
+					ignored = true
+				}
+			case *ast.AssignStmt:
+				for i, rhs := range x.Rhs {
+					if !isRecoverCall(rhs) || i >= len(x.Lhs) {
+						continue
+					}
+					if ident, ok := x.Lhs[i].(*ast.Ident); ok && ident.Name == "_" {
+						ignored = true
+					}
+				}
+			}
+			return !ignored
+		})
+		return !ignored
+	})
+	return ignored
+}
+
+// collectLocalTypes returns the names of all types declared in the file
+func collectLocalTypes(file *ast.File) map[string]bool {
+	types := make(map[string]bool)
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			types[spec.(*ast.TypeSpec).Name.Name] = true
+		}
+	}
+	return types
+}
+
+// detectCouldBeMethod reports whether a free function takes a locally declared
+// type (or a pointer to one) as its first parameter
+func detectCouldBeMethod(fn *ast.FuncDecl, localTypes map[string]bool) bool {
+	if fn.Recv != nil || fn.Type.Params == nil || len(fn.Type.Params.List) == 0 {
+		return false
+	}
+
+	paramType := fn.Type.Params.List[0].Type
+	if star, ok := paramType.(*ast.StarExpr); ok {
+		paramType = star.X
+	}
+	ident, ok := paramType.(*ast.Ident)
+	return ok && localTypes[ident.Name]
+}
+
+// detectReceiverUnused reports whether a method never references its receiver.
+// Shadowing is not accounted for, so a local of the same name counts as a use.
+func detectReceiverUnused(fn *ast.FuncDecl) bool {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return false
+	}
+
+	names := fn.Recv.List[0].Names
+	if len(names) == 0 || names[0].Name == "_" {
+		return true
+	}
+	if fn.Body == nil {
+		return false
+	}
+
+	recvName := names[0].Name
+	used := false
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && ident.Name == recvName {
+			used = true
+		}
+		return !used
+	})
+	return !used
+}
+
+// detectRecursion reports whether the function calls itself. Functions match
+// their bare name in calls; methods match through the receiver variable
+// (t.walk) or a method expression on the receiver type (T.walk), since a
+// bare call from a method reaches a package-level function instead.
+func detectRecursion(fn *ast.FuncDecl, calls []string) bool {
+	targets := map[string]bool{}
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		targets[fn.Name.Name] = true
+	} else {
+		recv := fn.Recv.List[0]
+		if len(recv.Names) > 0 && recv.Names[0].Name != "_" {
+			targets[recv.Names[0].Name+"."+fn.Name.Name] = true
+		}
+		if typeName := embeddedTypeName(recv.Type); typeName != "" {
+			targets[typeName+"."+fn.Name.Name] = true
+		}
+	}
+
+	for _, call := range calls {
+		if targets[stripTypeArgs(call)] {
+			return true
+		}
+	}
+	return false
+}
+
+// collectEnumMembers maps each locally declared type to the constants declared
+// with it, following the implicit repetition of the previous spec in const
+// blocks so the usual iota pattern is picked up
+func collectEnumMembers(file *ast.File, localTypes map[string]bool) map[string][]string {
+	members := make(map[string][]string)
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.CONST {
+			continue
+		}
+
+		var currentType ast.Expr
+		for _, spec := range genDecl.Specs {
+			valueSpec := spec.(*ast.ValueSpec)
+			if valueSpec.Type != nil || len(valueSpec.Values) > 0 {
+				currentType = valueSpec.Type
+			}
+			ident, ok := currentType.(*ast.Ident)
+			if !ok || !localTypes[ident.Name] {
+				continue
+			}
+			for _, name := range valueSpec.Names {
+				if name.Name != "_" {
+					members[ident.Name] = append(members[ident.Name], name.Name)
+				}
+			}
+		}
+	}
+	return members
+}
+
+// enumSwitchCoverage matches the case values of a switch against the known
+// enum types. It returns the enum type all case values belong to, and which of
+// its members were handled. hasDefault is true when a default clause exists.
+func enumSwitchCoverage(sw *ast.SwitchStmt, enumMembers map[string][]string) (enumType string, handled map[string]bool, hasDefault bool) {
+	handled = make(map[string]bool)
+	for _, stmt := range sw.Body.List {
+		clause := stmt.(*ast.CaseClause)
+		if clause.List == nil {
+			hasDefault = true
+		}
+		for _, expr := range clause.List {
+			if ident, ok := expr.(*ast.Ident); ok {
+				handled[ident.Name] = true
+			}
+		}
+	}
+	return switchEnumType(handled, enumMembers), handled, hasDefault
+}
+
+// switchEnumType returns the enum type that every handled case value is a
+// member of, or "" when there is none
+func switchEnumType(handled map[string]bool, enumMembers map[string][]string) string {
+	if len(handled) == 0 {
+		return ""
+	}
+
+	for typeName, names := range enumMembers {
+		known := make(map[string]bool, len(names))
+		for _, name := range names {
+			known[name] = true
+		}
+		all := true
+		for name := range handled {
+			if !known[name] {
+				all = false
+				break
+			}
+		}
+		if all {
+			return typeName
+		}
+	}
+	return ""
+}
+
+// detectNonExhaustiveSwitch reports whether the function switches over a local
+// enum-like type without a default clause and misses some of its members
+func detectNonExhaustiveSwitch(fn *ast.FuncDecl, enumMembers map[string][]string) bool {
+	if fn.Body == nil || len(enumMembers) == 0 {
+		return false
+	}
+
+	found := false
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		sw, ok := n.(*ast.SwitchStmt)
+		if !ok || sw.Tag == nil {
+			return !found
+		}
+		enumType, handled, hasDefault := enumSwitchCoverage(sw, enumMembers)
+		if enumType == "" || hasDefault {
+			return !found
+		}
+		for _, name := range enumMembers[enumType] {
+			if !handled[name] {
+				found = true
+				break
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// BuildCallMatrix turns the local call relationships between functions into an
+// adjacency matrix indexed by function name in source order
+func BuildCallMatrix(functions []FunctionInfo) CallMatrix {
+	index := make(map[string]int)
+	names := []string{}
+	for _, fn := range functions {
+		if _, ok := index[fn.Name]; !ok {
+			index[fn.Name] = len(names)
+			names = append(names, fn.Name)
+		}
+	}
+
+	matrix := make([][]int, len(names))
+	for i := range matrix {
+		matrix[i] = make([]int, len(names))
+	}
+	for _, fn := range functions {
+		for _, qualified := range localCalls(fn) {
+			call := callName(qualified)
+			if j, ok := index[call]; ok {
+				matrix[index[fn.Name]][j] = 1
+			}
+		}
+	}
+
+	return CallMatrix{
+		SchemaVersion: SchemaVersion,
+		Functions:     names,
+		Matrix:        matrix,
+	}
+}
+
+// looksLikeError guesses from its name whether expr holds an error value
+func looksLikeError(expr ast.Expr) bool {
+	ident, ok := expr.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	return ident.Name == "err" || strings.HasSuffix(ident.Name, "Err") || strings.HasSuffix(ident.Name, "err")
+}
+
+// countErrorfWrapping counts the fmt.Errorf calls that wrap an error with %w
+// and those that format what looks like an error with %v or %s instead,
+// losing the error chain
+func countErrorfWrapping(fn *ast.FuncDecl) (wrapped int, unwrapped int) {
+	if fn.Body == nil {
+		return 0, 0
+	}
+
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || sel.Sel.Name != "Errorf" {
+			return true
+		}
+		if pkg, ok := sel.X.(*ast.Ident); !ok || pkg.Name != "fmt" {
+			return true
+		}
+		lit, ok := call.Args[0].(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return true
+		}
+		format, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			return true
+		}
+
+		if strings.Contains(format, "%w") {
+			wrapped++
+			return true
+		}
+		if !strings.Contains(format, "%v") && !strings.Contains(format, "%s") {
+			return true
+		}
+		for _, arg := range call.Args[1:] {
+			if looksLikeError(arg) {
+				unwrapped++
+				break
+			}
+		}
+		return true
+	})
+	return wrapped, unwrapped
+}
+
+// isUnsizedSlice reports whether expr creates an empty slice with no capacity
+// hint: make([]T, 0), []T{} or nil
+func isUnsizedSlice(expr ast.Expr) bool {
+	switch e := expr.(type) {
+	case *ast.CallExpr:
+		ident, ok := e.Fun.(*ast.Ident)
+		if !ok || ident.Name != "make" || len(e.Args) != 2 {
+			return false
+		}
+		if arr, ok := e.Args[0].(*ast.ArrayType); !ok || arr.Len != nil {
+			return false
+		}
+		lit, ok := e.Args[1].(*ast.BasicLit)
+		return ok && lit.Value == "0"
+	case *ast.CompositeLit:
+		arr, ok := e.Type.(*ast.ArrayType)
+		return ok && arr.Len == nil && len(e.Elts) == 0
+	case *ast.Ident:
+		return e.Name == "nil"
+	}
+	return false
+}
+
+// collectUnsizedSlices returns the slice variables in the function that start
+// out empty without a capacity hint
+func collectUnsizedSlices(fn *ast.FuncDecl) map[string]bool {
+	slices := make(map[string]bool)
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.ValueSpec:
+			if len(x.Values) == 0 {
+				if arr, ok := x.Type.(*ast.ArrayType); ok && arr.Len == nil {
+					for _, name := range x.Names {
+						slices[name.Name] = true
+					}
+				}
+				return true
+			}
+			for i, value := range x.Values {
+				if i < len(x.Names) && isUnsizedSlice(value) {
+					slices[x.Names[i].Name] = true
+				}
+			}
+		case *ast.AssignStmt:
+			if x.Tok != token.DEFINE || len(x.Lhs) != len(x.Rhs) {
+				return true
+			}
+			for i, rhs := range x.Rhs {
+				if ident, ok := x.Lhs[i].(*ast.Ident); ok && isUnsizedSlice(rhs) {
+					slices[ident.Name] = true
+				}
+			}
+		}
+		return true
+	})
+	return slices
+}
+
+// detectAppendWithoutPrealloc reports whether a loop appends to a slice that
+// was created without a capacity hint
+func detectAppendWithoutPrealloc(fn *ast.FuncDecl) bool {
+	if fn.Body == nil {
+		return false
+	}
+
+	slices := collectUnsizedSlices(fn)
+	if len(slices) == 0 {
+		return false
+	}
+
+	found := false
+	inspectLoop := func(body *ast.BlockStmt) {
+		ast.Inspect(body, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) == 0 {
+				return !found
+			}
+			if ident, ok := call.Fun.(*ast.Ident); !ok || ident.Name != "append" {
+				return !found
+			}
+			if target, ok := call.Args[0].(*ast.Ident); ok && slices[target.Name] {
+				found = true
+			}
+			return !found
+		})
+	}
+
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.ForStmt:
+			inspectLoop(x.Body)
+		case *ast.RangeStmt:
+			inspectLoop(x.Body)
+		}
+		return !found
+	})
+	return found
+}
+
+// nodeString renders an AST node back to Go source
+func nodeString(fSet *token.FileSet, node ast.Node) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fSet, node); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// extractDefers returns the source of every call deferred in the function
+func extractDefers(fn *ast.FuncDecl, fSet *token.FileSet) []string {
+	defers := []string{}
+	if fn.Body == nil {
+		return defers
+	}
+
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		if deferStmt, ok := n.(*ast.DeferStmt); ok {
+			defers = append(defers, nodeString(fSet, deferStmt.Call))
+		}
+		return true
+	})
+	return defers
+}
+
+// detectDeferGoPanic reports whether the body defers, starts goroutines or
+// calls the builtin panic, and lists what each go statement launches. A
+// function literal launch is named func@line, as in Closures.
+func detectDeferGoPanic(fn *ast.FuncDecl, fSet *token.FileSet) (hasDefer, hasGoroutine, hasPanic bool, goCallSites []string) {
+	goCallSites = []string{}
+	if fn.Body == nil {
+		return false, false, false, goCallSites
+	}
+
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.DeferStmt:
+			hasDefer = true
+		case *ast.GoStmt:
+			hasGoroutine = true
+			if lit, ok := x.Call.Fun.(*ast.FuncLit); ok {
+				goCallSites = append(goCallSites, fmt.Sprintf("func@%d", fSet.Position(lit.Pos()).Line))
+			} else {
+				goCallSites = append(goCallSites, nodeString(fSet, x.Call.Fun))
+			}
+		case *ast.CallExpr:
+			if ident, ok := x.Fun.(*ast.Ident); ok && ident.Name == "panic" {
+				hasPanic = true
+			}
+		}
+		return true
+	})
+	return hasDefer, hasGoroutine, hasPanic, goCallSites
+}
+
+// detectLabels lists the labels declared in the body in source order and
+// reports whether it uses goto, kept apart from break or continue with a
+// label, which stay structured. Function literals are searched as well.
+func detectLabels(fn *ast.FuncDecl) (hasGoto, hasLabeledBranch bool, labels []string) {
+	labels = []string{}
+	if fn.Body == nil {
+		return false, false, labels
+	}
+
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.LabeledStmt:
+			labels = append(labels, x.Label.Name)
+		case *ast.BranchStmt:
+			switch {
+			case x.Tok == token.GOTO:
+				hasGoto = true
+			case x.Label != nil && (x.Tok == token.BREAK || x.Tok == token.CONTINUE):
+				hasLabeledBranch = true
+			}
+		}
+		return true
+	})
+	return hasGoto, hasLabeledBranch, labels
+}
+
+// collectPackageVars returns the names of the package-level variables
+func collectPackageVars(file *ast.File) map[string]bool {
+	vars := make(map[string]bool)
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			for _, name := range spec.(*ast.ValueSpec).Names {
+				if name.Name != "_" {
+					vars[name.Name] = true
+				}
+			}
+		}
+	}
+	return vars
+}
+
+// collectLocalNames returns every name the function declares for itself:
+// receiver, parameters, results and local variables. These shadow globals.
+func collectLocalNames(fn *ast.FuncDecl) map[string]bool {
+	locals := make(map[string]bool)
+	addFields := func(fields *ast.FieldList) {
+		if fields == nil {
+			return
+		}
+		for _, field := range fields.List {
+			for _, name := range field.Names {
+				locals[name.Name] = true
+			}
+		}
+	}
+	addFields(fn.Recv)
+	addFields(fn.Type.Params)
+	addFields(fn.Type.Results)
+
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.AssignStmt:
+			if x.Tok == token.DEFINE {
+				for _, lhs := range x.Lhs {
+					if ident, ok := lhs.(*ast.Ident); ok {
+						locals[ident.Name] = true
+					}
+				}
+			}
+		case *ast.RangeStmt:
+			if x.Tok == token.DEFINE {
+				for _, expr := range []ast.Expr{x.Key, x.Value} {
+					if ident, ok := expr.(*ast.Ident); ok {
+						locals[ident.Name] = true
+					}
+				}
+			}
+		case *ast.ValueSpec:
+			for _, name := range x.Names {
+				locals[name.Name] = true
+			}
+		case *ast.FuncLit:
+			addFields(x.Type.Params)
+			addFields(x.Type.Results)
+		}
+		return true
+	})
+	return locals
+}
+
+// rootIdent returns the variable at the base of an lvalue like a.b[i].c
+func rootIdent(expr ast.Expr) *ast.Ident {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e
+	case *ast.SelectorExpr:
+		return rootIdent(e.X)
+	case *ast.IndexExpr:
+		return rootIdent(e.X)
+	case *ast.StarExpr:
+		return rootIdent(e.X)
+	case *ast.ParenExpr:
+		return rootIdent(e.X)
+	}
+	return nil
+}
+
+// extractGlobalAccess returns the package-level variables the function reads
+// and the ones it assigns to. Locals that share a global's name anywhere in
+// the function hide it entirely, which keeps the heuristic conservative.
+func extractGlobalAccess(fn *ast.FuncDecl, globals map[string]bool) (reads []string, writes []string) {
+	reads, writes = []string{}, []string{}
+	if fn.Body == nil || len(globals) == 0 {
+		return reads, writes
+	}
+
+	locals := collectLocalNames(fn)
+	isGlobal := func(ident *ast.Ident) bool {
+		return ident != nil && globals[ident.Name] && !locals[ident.Name]
+	}
+
+	readSet := make(map[string]bool)
+	writeSet := make(map[string]bool)
+	// Plain assignment targets are writes only; everything else is a read
+	writeOnly := make(map[*ast.Ident]bool)
+
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range x.Lhs {
+				ident := rootIdent(lhs)
+				if !isGlobal(ident) {
+					continue
+				}
+				writeSet[ident.Name] = true
+				if _, direct := lhs.(*ast.Ident); direct && x.Tok == token.ASSIGN {
+					writeOnly[ident] = true
+				}
+			}
+		case *ast.IncDecStmt:
+			if ident := rootIdent(x.X); isGlobal(ident) {
+				writeSet[ident.Name] = true
+			}
+		}
+		return true
+	})
+
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.SelectorExpr:
+			// Only the operand can be a variable, Sel is a field or method
+			ast.Inspect(x.X, func(n ast.Node) bool {
+				if ident, ok := n.(*ast.Ident); ok && isGlobal(ident) && !writeOnly[ident] {
+					readSet[ident.Name] = true
+				}
+				return true
+			})
+			return false
+		case *ast.KeyValueExpr:
+			// Keys in struct literals are field names
+			if _, ok := x.Key.(*ast.Ident); ok {
+				ast.Inspect(x.Value, func(n ast.Node) bool {
+					if ident, ok := n.(*ast.Ident); ok && isGlobal(ident) {
+						readSet[ident.Name] = true
+					}
+					return true
+				})
+				return false
+			}
+		case *ast.Ident:
+			if isGlobal(x) && !writeOnly[x] {
+				readSet[x.Name] = true
+			}
+		}
+		return true
+	})
+
+	for name := range readSet {
+		reads = append(reads, name)
+	}
+	for name := range writeSet {
+		writes = append(writes, name)
+	}
+	sort.Strings(reads)
+	sort.Strings(writes)
+	return reads, writes
+}
+
+// computeFanMetrics sets FanIn and FanOut on every function from the local
+// call graph. Self-calls count toward neither; IsRecursive covers them.
+func computeFanMetrics(functions []FunctionInfo) {
+	callers := localCallers(functions)
+	_, edges := localCallGraph(functions)
+	for i := range functions {
+		fn := &functions[i]
+		fn.FanIn = len(callers[callerKey(*fn)])
+		fn.FanOut = 0
+		for _, callee := range edges[fn.Name] {
+			if callee != fn.Name {
+				fn.FanOut++
+			}
+		}
+	}
+}
+
+// localCallGraph returns the distinct function names in source order and, for
+// each, the sorted local functions it calls
+func localCallGraph(functions []FunctionInfo) ([]string, map[string][]string) {
+	var names []string
+	edges := make(map[string][]string)
+	for _, fn := range functions {
+		if _, ok := edges[fn.Name]; !ok {
+			names = append(names, fn.Name)
+			edges[fn.Name] = []string{}
+		}
+	}
+
+	for _, fn := range functions {
+		for _, qualified := range localCalls(fn) {
+			call := callName(qualified)
+			if _, ok := edges[call]; !ok {
+				continue
+			}
+			seen := false
+			for _, callee := range edges[fn.Name] {
+				if callee == call {
+					seen = true
+					break
+				}
+			}
+			if !seen {
+				edges[fn.Name] = append(edges[fn.Name], call)
+			}
+		}
+	}
+	for _, callees := range edges {
+		sort.Strings(callees)
+	}
+	return names, edges
+}
+
+// buildCallGraph returns the edges between functions defined in the file,
+// ordered by caller position and then callee name
+func buildCallGraph(functions []FunctionInfo) []Edge {
+	names, edges := localCallGraph(functions)
+	graph := []Edge{}
+	for _, name := range names {
+		for _, callee := range edges[name] {
+			graph = append(graph, Edge{From: name, To: callee})
+		}
+	}
+	return graph
+}
+
+// receiverTypeParams returns the type parameter names a generic method's
+// receiver binds, e.g. K and V for (m *Map[K, V])
+func receiverTypeParams(recv *ast.FieldList) []string {
+	params := []string{}
+	if recv == nil || len(recv.List) == 0 {
+		return params
+	}
+
+	t := recv.List[0].Type
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	var indices []ast.Expr
+	switch t := t.(type) {
+	case *ast.IndexExpr:
+		indices = []ast.Expr{t.Index}
+	case *ast.IndexListExpr:
+		indices = t.Indices
+	}
+	for _, index := range indices {
+		params = append(params, ExtractTypeString(index))
+	}
+	return params
+}
+
+// isVariadic reports whether the final parameter of the signature is ...T
+func isVariadic(fn *ast.FuncType) bool {
+	if fn.Params == nil || len(fn.Params.List) == 0 {
+		return false
+	}
+	_, ok := fn.Params.List[len(fn.Params.List)-1].Type.(*ast.Ellipsis)
+	return ok
+}
+
+// isExportedAPI reports whether fn is part of the package's exported API: an
+// exported function, or an exported method of an exported type
+func isExportedAPI(fn *ast.FuncDecl) bool {
+	if !fn.Name.IsExported() {
+		return false
+	}
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return true
+	}
+	return token.IsExported(embeddedTypeName(fn.Recv.List[0].Type))
+}
+
+// isInitFunc reports whether fn is a package initializer, which can't be
+// called and may be declared more than once per file
+func isInitFunc(fn *ast.FuncDecl) bool {
+	return fn.Recv == nil && fn.Name.Name == "init" && fn.Type.TypeParams == nil &&
+		fn.Type.Params.NumFields() == 0 && fn.Type.Results.NumFields() == 0
+}
+
+// dotNodeName labels a function for Graphviz, Receiver.Method for methods
+func dotNodeName(fn FunctionInfo) string {
+	if fn.IsMethod && fn.Receiver != "" {
+		return strings.TrimPrefix(fn.Receiver, "*") + "." + fn.Name
+	}
+	if fn.IsInit {
+		// A file may declare several, so keep them apart
+		return fmt.Sprintf("init#%d", fn.InitOrder)
+	}
+	return fn.Name
+}
+
+// RenderDOT renders the local call graph as a Graphviz digraph. A call to a
+// method name links to every method of that name since receivers aren't
+// resolved.
+func RenderDOT(functions []FunctionInfo) string {
+	nodesByName := make(map[string][]string)
+	var nodes []string
+	seen := make(map[string]bool)
+	for _, fn := range functions {
+		node := dotNodeName(fn)
+		if !seen[node] {
+			seen[node] = true
+			nodes = append(nodes, node)
+			nodesByName[fn.Name] = append(nodesByName[fn.Name], node)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("digraph calls {\n")
+	for _, node := range nodes {
+		fmt.Fprintf(&b, "\t%s;\n", strconv.Quote(node))
+	}
+
+	drawn := make(map[string]bool)
+	for _, fn := range functions {
+		from := dotNodeName(fn)
+		for _, qualified := range localCalls(fn) {
+			for _, to := range nodesByName[callName(qualified)] {
+				edge := strconv.Quote(from) + " -> " + strconv.Quote(to)
+				if !drawn[edge] {
+					drawn[edge] = true
+					fmt.Fprintf(&b, "\t%s;\n", edge)
+				}
+			}
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// stronglyConnectedComponents runs Tarjan's algorithm over the graph. The
+// components come out in reverse topological order, so callees precede their
+// callers.
+func stronglyConnectedComponents(names []string, edges map[string][]string) [][]string {
+	index := 0
+	indices := make(map[string]int)
+	lowLinks := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var components [][]string
+
+	var strongConnect func(name string)
+	strongConnect = func(name string) {
+		indices[name] = index
+		lowLinks[name] = index
+		index++
+		stack = append(stack, name)
+		onStack[name] = true
+
+		for _, callee := range edges[name] {
+			if _, visited := indices[callee]; !visited {
+				strongConnect(callee)
+				lowLinks[name] = min(lowLinks[name], lowLinks[callee])
+			} else if onStack[callee] {
+				lowLinks[name] = min(lowLinks[name], indices[callee])
+			}
+		}
+
+		if lowLinks[name] == indices[name] {
+			var component []string
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component = append(component, top)
+				if top == name {
+					break
+				}
+			}
+			components = append(components, component)
+		}
+	}
+
+	for _, name := range names {
+		if _, visited := indices[name]; !visited {
+			strongConnect(name)
+		}
+	}
+	return components
+}
+
+// BuildTopoOrder orders functions so callees come before callers, or reports
+// the call cycles when no such order exists
+func BuildTopoOrder(functions []FunctionInfo) TopoOrder {
+	names, edges := localCallGraph(functions)
+	components := stronglyConnectedComponents(names, edges)
+
+	result := TopoOrder{
+		SchemaVersion: SchemaVersion,
+		Order:         []string{},
+		Cycles:        [][]string{},
+	}
+	for _, component := range components {
+		selfLoop := false
+		for _, callee := range edges[component[0]] {
+			if callee == component[0] {
+				selfLoop = true
+			}
+		}
+		if len(component) > 1 || selfLoop {
+			sort.Strings(component)
+			result.Cycles = append(result.Cycles, component)
+		}
+	}
+
+	result.Acyclic = len(result.Cycles) == 0
+	if result.Acyclic {
+		for _, component := range components {
+			result.Order = append(result.Order, component[0])
+		}
+	}
+	return result
+}
+
+// OrderTopologically returns functions reordered so callees come before their
+// callers. Functions in a call cycle, recursive ones included, can't all
+// precede each other; they stay in source order and each cycle is returned by
+// name. Functions sharing a name, like methods on different types, are kept
+// together since calls aren't resolved to receivers.
+func OrderTopologically(functions []FunctionInfo) ([]FunctionInfo, [][]string) {
+	names, edges := localCallGraph(functions)
+	cycles := BuildTopoOrder(functions).Cycles
+
+	byName := make(map[string][]FunctionInfo)
+	for _, fn := range functions {
+		byName[fn.Name] = append(byName[fn.Name], fn)
+	}
+
+	position := make(map[string]int, len(names))
+	for i, name := range names {
+		position[name] = i
+	}
+
+	ordered := make([]FunctionInfo, 0, len(functions))
+	for _, component := range stronglyConnectedComponents(names, edges) {
+		sort.Slice(component, func(i, j int) bool {
+			return position[component[i]] < position[component[j]]
+		})
+		for _, name := range component {
+			ordered = append(ordered, byName[name]...)
+		}
+	}
+	return ordered, cycles
+}
+
+// impurePackages are import paths whose functions all do I/O or depend on the
+// environment. fmt and time are mostly pure and are handled by function name.
+var impurePackages = map[string]bool{
+	"bufio":        true,
+	"crypto/rand":  true,
+	"database/sql": true,
+	"io":           true,
+	"io/ioutil":    true,
+	"log":          true,
+	"math/rand":    true,
+	"math/rand/v2": true,
+	"net":          true,
+	"net/http":     true,
+	"os":           true,
+	"os/exec":      true,
+	"runtime":      true,
+	"sync":         true,
+	"syscall":      true,
+}
+
+// impureFuncs are the side-effecting functions of otherwise pure packages
+var impureFuncs = map[string]map[string]bool{
+	"fmt": {
+		"Print": true, "Printf": true, "Println": true,
+		"Fprint": true, "Fprintf": true, "Fprintln": true,
+		"Scan": true, "Scanf": true, "Scanln": true,
+		"Fscan": true, "Fscanf": true, "Fscanln": true,
+	},
+	"time": {
+		"Now": true, "Since": true, "Until": true, "Sleep": true,
+		"After": true, "AfterFunc": true, "Tick": true,
+		"NewTicker": true, "NewTimer": true,
+	},
+}
+
+// importLocalNames maps the name each import is referred to by in the file to
+// its path. Blank and dot imports are left out.
+func importLocalNames(file *ast.File) map[string]string {
+	names := make(map[string]string)
+	for _, imp := range file.Imports {
+		path := strings.Trim(imp.Path.Value, "\"")
+		name := path[strings.LastIndex(path, "/")+1:]
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		if name == "_" || name == "." {
+			continue
+		}
+		names[name] = path
+	}
+	return names
+}
+
+// testKinds maps the name prefixes go test recognizes to the kind reported
+// and the testing type the single parameter must point to
+var testKinds = []struct {
+	prefix, kind, param string
+}{
+	{"Test", "test", "T"},
+	{"Benchmark", "benchmark", "B"},
+	{"Fuzz", "fuzz", "F"},
+	{"Example", "example", ""},
+}
+
+// detectTestKind classifies the function as go test would: a Test, Benchmark
+// or Fuzz prefix not followed by a lowercase letter, taking *testing.T, B or
+// F respectively. Examples take no parameters so only their name is checked.
+// It returns "" for anything else.
+func detectTestKind(fn *ast.FuncDecl, importNames map[string]string) string {
+	if fn.Recv != nil {
+		return ""
+	}
+
+	for _, tk := range testKinds {
+		rest, ok := strings.CutPrefix(fn.Name.Name, tk.prefix)
+		if !ok {
+			continue
+		}
+		if rest != "" && unicode.IsLower([]rune(rest)[0]) {
+			return ""
+		}
+		if tk.param == "" {
+			return tk.kind
+		}
+
+		params := fn.Type.Params.List
+		if len(params) != 1 || len(params[0].Names) > 1 {
+			return ""
+		}
+		star, ok := params[0].Type.(*ast.StarExpr)
+		if !ok {
+			return ""
+		}
+		sel, ok := star.X.(*ast.SelectorExpr)
+		if !ok || sel.Sel.Name != tk.param {
+			return ""
+		}
+		if pkg, ok := sel.X.(*ast.Ident); !ok || importNames[pkg.Name] != "testing" {
+			return ""
+		}
+		return tk.kind
+	}
+	return ""
+}
+
+// extractUsedImports returns the sorted import paths the function refers to,
+// in its signature or body, through a pkg.Name selector. Identifiers the
+// parser resolved to a local declaration are skipped so a variable shadowing
+// a package name doesn't count. Dot imports have no qualifier and are not
+// detected.
+func extractUsedImports(fn *ast.FuncDecl, importNames map[string]string) []string {
+	used := make(map[string]bool)
+	ast.Inspect(fn, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Obj == nil {
+			if path, ok := importNames[pkg.Name]; ok {
+				used[path] = true
+			}
+		}
+		return true
+	})
+
+	paths := make([]string, 0, len(used))
+	for path := range used {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// detectSideEffects reports whether the function starts goroutines or calls
+// into packages that do I/O, read the clock or use randomness
+func detectSideEffects(fn *ast.FuncDecl, importNames map[string]string) bool {
+	if fn.Body == nil {
+		return false
+	}
+
+	found := false
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.GoStmt:
+			found = true
+		case *ast.CallExpr:
+			sel, ok := x.Fun.(*ast.SelectorExpr)
+			if !ok {
+				break
+			}
+			pkg, ok := sel.X.(*ast.Ident)
+			if !ok {
+				break
+			}
+			path, ok := importNames[pkg.Name]
+			if !ok {
+				break
+			}
+			if impurePackages[path] || impureFuncs[path][sel.Sel.Name] {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// extractSwitches returns the tagged expression switches in the file in
+// source order
+func extractSwitches(file *ast.File, fSet *token.FileSet) []SwitchInfo {
+	switches := []SwitchInfo{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		sw, ok := n.(*ast.SwitchStmt)
+		if !ok || sw.Tag == nil {
+			return true
+		}
+		_, handled, hasDefault := enumSwitchCoverage(sw, nil)
+		info := SwitchInfo{
+			Line:       fSet.Position(sw.Pos()).Line,
+			Cases:      make([]string, 0, len(handled)),
+			HasDefault: hasDefault,
+		}
+		for name := range handled {
+			info.Cases = append(info.Cases, name)
+		}
+		sort.Strings(info.Cases)
+		switches = append(switches, info)
+		return true
+	})
+	return switches
+}
+
+// buildEnumCoverage checks the switches against the enum types and reports,
+// per type, the members no switch handles
+func buildEnumCoverage(switches []SwitchInfo, enumMembers map[string][]string) []EnumCoverage {
+	counts := make(map[string]int)
+	handled := make(map[string]map[string]bool)
+	for _, sw := range switches {
+		cases := make(map[string]bool, len(sw.Cases))
+		for _, name := range sw.Cases {
+			cases[name] = true
+		}
+		enumType := switchEnumType(cases, enumMembers)
+		if enumType == "" {
+			continue
+		}
+		counts[enumType]++
+		if handled[enumType] == nil {
+			handled[enumType] = make(map[string]bool)
+		}
+		for name := range cases {
+			handled[enumType][name] = true
+		}
+	}
+
+	types := make([]string, 0, len(enumMembers))
+	for typeName := range enumMembers {
+		types = append(types, typeName)
+	}
+	sort.Strings(types)
+
+	coverage := []EnumCoverage{}
+	for _, typeName := range types {
+		entry := EnumCoverage{
+			Type:      typeName,
+			Switches:  counts[typeName],
+			Handled:   []string{},
+			Unhandled: []string{},
+		}
+		for _, name := range enumMembers[typeName] {
+			if handled[typeName][name] {
+				entry.Handled = append(entry.Handled, name)
+			} else {
+				entry.Unhandled = append(entry.Unhandled, name)
+			}
+		}
+		coverage = append(coverage, entry)
+	}
+	return coverage
+}
+
+// packageEnumCoverage checks the switches of all the files against the
+// constants declared with a type from any of them, in file order
+func packageEnumCoverage(files []FileInfo) []EnumCoverage {
+	localTypes := make(map[string]bool)
+	for _, info := range files {
+		for _, t := range info.Types {
+			localTypes[t.Name] = true
+		}
+	}
+
+	enumMembers := make(map[string][]string)
+	var switches []SwitchInfo
+	for _, info := range files {
+		for _, c := range info.Constants {
+			if localTypes[c.Type] && c.Name != "_" {
+				enumMembers[c.Type] = append(enumMembers[c.Type], c.Name)
+			}
+		}
+		switches = append(switches, info.Switches...)
+	}
+	return buildEnumCoverage(switches, enumMembers)
+}
+
+// extractImportSpecs returns the imports with their aliases and groups. Like
+// gofmt, it takes a blank line, or any other line that isn't a spec or its
+// doc comment, between two specs to start a new group.
+func extractImportSpecs(file *ast.File, fSet *token.FileSet) []ImportInfo {
+	imports := []ImportInfo{}
+	group := -1
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.IMPORT {
+			continue
+		}
+
+		group++
+		prevEnd := 0
+		for i, spec := range genDecl.Specs {
+			imp := spec.(*ast.ImportSpec)
+			start := imp.Pos()
+			if imp.Doc != nil {
+				start = imp.Doc.Pos()
+			}
+			if i > 0 && fSet.Position(start).Line > prevEnd+1 {
+				group++
+			}
+			prevEnd = fSet.Position(imp.End()).Line
+
+			info := ImportInfo{
+				Path:  strings.Trim(imp.Path.Value, "\""),
+				Group: group,
+				Line:  fSet.Position(imp.Pos()).Line,
+			}
+			if imp.Name != nil {
+				info.Alias = imp.Name.Name
+			}
+			imports = append(imports, info)
+		}
+	}
+	return imports
+}
+
+// extractImports returns the imports
+func extractImports(file *ast.File) []string {
+	var imports []string
+
+	for _, imp := range file.Imports {
+		path := strings.Trim(imp.Path.Value, "\"")
+		if imp.Name != nil {
+			imports = append(imports, imp.Name.Name+" "+path)
+		} else {
+			imports = append(imports, path)
+		}
+	}
+	return imports
+}
+
+// parseJSONTag returns the name and omitempty option from a raw struct tag
+// literal. skip is true for fields tagged `json:"-"`.
+func parseJSONTag(tag *ast.BasicLit) (name string, omitEmpty bool, skip bool) {
+	if tag == nil {
+		return "", false, false
+	}
+	raw, err := strconv.Unquote(tag.Value)
+	if err != nil {
+		return "", false, false
+	}
+	value, ok := reflect.StructTag(raw).Lookup("json")
+	if !ok {
+		return "", false, false
+	}
+	if value == "-" {
+		return "", false, true
+	}
+
+	parts := strings.Split(value, ",")
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return parts[0], omitEmpty, false
+}
+
+// parseStructTag splits a raw struct tag into its key:"value" pairs, using the
+// same conventional format reflect.StructTag.Lookup understands. Parsing
+// stops at the first malformed pair, as it does for Lookup.
+func parseStructTag(raw string) map[string]string {
+	tags := make(map[string]string)
+	for raw != "" {
+		raw = strings.TrimLeft(raw, " ")
+		i := 0
+		for i < len(raw) && raw[i] > ' ' && raw[i] != ':' && raw[i] != '"' && raw[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(raw) || raw[i] != ':' || raw[i+1] != '"' {
+			break
+		}
+		key := raw[:i]
+		raw = raw[i+1:]
+
+		// Find the closing quote, skipping escaped characters
+		i = 1
+		for i < len(raw) && raw[i] != '"' {
+			if raw[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(raw) {
+			break
+		}
+		value, err := strconv.Unquote(raw[:i+1])
+		if err != nil {
+			break
+		}
+		tags[key] = value
+		raw = raw[i+1:]
+	}
+	return tags
+}
+
+// embeddedTypeName returns the bare type name of an embedded field, e.g. Base
+// for *Base or pkg.Base
+func embeddedTypeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		return embeddedTypeName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.IndexExpr:
+		return embeddedTypeName(t.X)
+	case *ast.IndexListExpr:
+		return embeddedTypeName(t.X)
+	}
+	return ""
+}
+
+// jsonFieldCandidate is a JSONField along with how deep it was promoted from
+type jsonFieldCandidate struct {
+	field  JSONField
+	depth  int
+	tagged bool
+}
+
+// collectJSONFields walks a struct's fields the way encoding/json does,
+// promoting fields from embedded structs defined in the same file
+func collectJSONFields(st *ast.StructType, structs map[string]*ast.StructType, prefix string, depth int, visiting map[*ast.StructType]bool) []jsonFieldCandidate {
+	var candidates []jsonFieldCandidate
+	if visiting[st] {
+		return candidates
+	}
+	visiting[st] = true
+	defer delete(visiting, st)
+
+	for _, field := range st.Fields.List {
+		tagName, omitEmpty, skip := parseJSONTag(field.Tag)
+		if skip {
+			continue
+		}
+		typeStr := ExtractTypeString(field.Type)
+
+		if len(field.Names) == 0 {
+			typeName := embeddedTypeName(field.Type)
+			if tagName == "" {
+				_, qualified := field.Type.(*ast.SelectorExpr)
+				if embedded, ok := structs[typeName]; ok && !qualified {
+					candidates = append(candidates, collectJSONFields(embedded, structs, prefix+typeName+".", depth+1, visiting)...)
+					continue
+				}
+				if !ast.IsExported(typeName) {
+					continue
+				}
+				// Embedded types from other packages may be promoted too, but
+				// their fields can't be seen from this file
+				candidates = append(candidates, jsonFieldCandidate{
+					field: JSONField{Name: typeName, Field: prefix + typeName, Type: typeStr, OmitEmpty: omitEmpty, Unresolved: true},
+					depth: depth,
+				})
+				continue
+			}
+			candidates = append(candidates, jsonFieldCandidate{
+				field:  JSONField{Name: tagName, Field: prefix + typeName, Type: typeStr, OmitEmpty: omitEmpty},
+				depth:  depth,
+				tagged: true,
+			})
+			continue
+		}
+
+		for _, name := range field.Names {
+			if !name.IsExported() {
+				continue
+			}
+			jsonName := tagName
+			if jsonName == "" {
+				jsonName = name.Name
+			}
+			candidates = append(candidates, jsonFieldCandidate{
+				field:  JSONField{Name: jsonName, Field: prefix + name.Name, Type: typeStr, OmitEmpty: omitEmpty},
+				depth:  depth,
+				tagged: tagName != "",
+			})
+		}
+	}
+	return candidates
+}
+
+// resolveJSONFields applies encoding/json's precedence rules: the shallowest
+// field wins, a tagged field beats untagged ones at the same depth, and any
+// remaining conflict drops the name entirely
+func resolveJSONFields(candidates []jsonFieldCandidate) []JSONField {
+	byName := make(map[string][]jsonFieldCandidate)
+	var order []string
+	for _, c := range candidates {
+		if _, ok := byName[c.field.Name]; !ok {
+			order = append(order, c.field.Name)
+		}
+		byName[c.field.Name] = append(byName[c.field.Name], c)
+	}
+
+	fields := []JSONField{}
+	for _, name := range order {
+		group := byName[name]
+		minDepth := group[0].depth
+		for _, c := range group {
+			if c.depth < minDepth {
+				minDepth = c.depth
+			}
+		}
+
+		var shallow, tagged []jsonFieldCandidate
+		for _, c := range group {
+			if c.depth != minDepth {
+				continue
+			}
+			shallow = append(shallow, c)
+			if c.tagged {
+				tagged = append(tagged, c)
+			}
+		}
+
+		switch {
+		case len(shallow) == 1:
+			fields = append(fields, shallow[0].field)
+		case len(tagged) == 1:
+			fields = append(fields, tagged[0].field)
+		}
+	}
+	return fields
+}
+
+// extractFields returns the fields of a struct type in declaration order
+func extractFields(st *ast.StructType) []FieldInfo {
+	fields := []FieldInfo{}
+	for _, field := range st.Fields.List {
+		typeStr := ExtractTypeString(field.Type)
+		tag := ""
+		if field.Tag != nil {
+			if unquoted, err := strconv.Unquote(field.Tag.Value); err == nil {
+				tag = unquoted
+			}
+		}
+
+		doc := extractDocstring(field.Doc)
+		if doc == "" {
+			doc = extractDocstring(field.Comment)
+		}
+
+		if len(field.Names) == 0 {
+			fields = append(fields, FieldInfo{
+				Name:      embeddedTypeName(field.Type),
+				Type:      typeStr,
+				Tag:       tag,
+				Tags:      parseStructTag(tag),
+				Embedded:  true,
+				DocString: doc,
+			})
+			continue
+		}
+		for _, name := range field.Names {
+			fields = append(fields, FieldInfo{
+				Name:      name.Name,
+				Type:      typeStr,
+				Tag:       tag,
+				Tags:      parseStructTag(tag),
+				DocString: doc,
+			})
+		}
+	}
+	return fields
+}
+
+// extractStructs returns the struct type declarations in the file
+func extractStructs(file *ast.File, fSet *token.FileSet, annotationPrefixes []string) []StructInfo {
+	structs := make(map[string]*ast.StructType)
+	docs := make(map[*ast.TypeSpec]*ast.CommentGroup)
+	var specs []*ast.TypeSpec
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+			if st, ok := typeSpec.Type.(*ast.StructType); ok {
+				structs[typeSpec.Name.Name] = st
+				docs[typeSpec] = typeSpecDoc(genDecl, typeSpec)
+				specs = append(specs, typeSpec)
+			}
+		}
+	}
+
+	result := []StructInfo{}
+	for _, typeSpec := range specs {
+		st := structs[typeSpec.Name.Name]
+		candidates := collectJSONFields(st, structs, "", 0, make(map[*ast.StructType]bool))
+		result = append(result, StructInfo{
+			Name:        typeSpec.Name.Name,
+			Exported:    typeSpec.Name.IsExported(),
+			StartLine:   fSet.Position(typeSpec.Pos()).Line,
+			EndLine:     fSet.Position(typeSpec.End()).Line,
+			Fields:      extractFields(st),
+			JSONFields:  resolveJSONFields(candidates),
+			Annotations: extractAnnotations(docs[typeSpec], annotationPrefixes),
+			IsAlias:     typeSpec.Assign.IsValid(),
+			DocString:   extractDocstring(docs[typeSpec]),
+
+			Implements:        []string{},
+			PointerImplements: []string{},
+		})
+	}
+	return result
+}
+
+// typeKind names the kind of type expression a declaration is based on
+func typeKind(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StructType:
+		return "struct"
+	case *ast.InterfaceType:
+		return "interface"
+	case *ast.FuncType:
+		return "func"
+	case *ast.MapType:
+		return "map"
+	case *ast.ChanType:
+		return "chan"
+	case *ast.StarExpr:
+		return "pointer"
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "slice"
+		}
+		return "array"
+	case *ast.ParenExpr:
+		return typeKind(t.X)
+	}
+	return "named"
+}
+
+// extractTypes returns every type declaration in the file. IsAlias separates
+// `type A = B`, which is B under another name, from `type A B`, which
+// defines a new type with B's underlying type.
+func extractTypes(file *ast.File, fSet *token.FileSet, annotationPrefixes []string) []TypeInfo {
+	methods, pointerMethods := declaredMethods(file)
+	result := []TypeInfo{}
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+			doc := typeSpecDoc(genDecl, typeSpec)
+			info := TypeInfo{
+				Name:           typeSpec.Name.Name,
+				Exported:       typeSpec.Name.IsExported(),
+				Kind:           typeKind(typeSpec.Type),
+				Type:           ExtractTypeString(typeSpec.Type),
+				IsAlias:        typeSpec.Assign.IsValid(),
+				TypeParams:     extractTypeParams(typeSpec.TypeParams),
+				StartLine:      fSet.Position(typeSpec.Pos()).Line,
+				EndLine:        fSet.Position(typeSpec.End()).Line,
+				DocString:      extractDocstring(doc),
+				Annotations:    extractAnnotations(doc, annotationPrefixes),
+				Fields:         []FieldInfo{},
+				Embedded:       []string{},
+				Methods:        []MethodSignature{},
+				PointerMethods: []MethodSignature{},
+			}
+			switch t := ast.Unparen(typeSpec.Type).(type) {
+			case *ast.StructType:
+				info.Fields = extractFields(t)
+			case *ast.InterfaceType:
+				info.Methods, info.Embedded = interfaceMembers(t)
+			}
+			// Methods can't be declared on an alias, nor on an interface
+			if !info.IsAlias && info.Kind != "interface" {
+				info.Methods = append(info.Methods, methods[info.Name]...)
+				info.PointerMethods = append(info.PointerMethods, pointerMethods[info.Name]...)
+			}
+			result = append(result, info)
+		}
+	}
+	return result
+}
+
+// declaredMethods returns the signatures of the methods declared in the file
+// by receiver type name, split into value and pointer receivers
+func declaredMethods(file *ast.File) (map[string][]MethodSignature, map[string][]MethodSignature) {
+	methods := make(map[string][]MethodSignature)
+	pointerMethods := make(map[string][]MethodSignature)
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv == nil || len(fn.Recv.List) == 0 {
+			continue
+		}
+		signature := MethodSignature{
+			Name:       fn.Name.Name,
+			Parameters: extractParameters(fn.Type.Params),
+			Returns:    extractReturnTypes(fn.Type.Results),
+			DocString:  extractDocstring(fn.Doc),
+		}
+		receiver := ExtractTypeString(fn.Recv.List[0].Type)
+		name := receiverTypeName(receiver)
+		if strings.HasPrefix(receiver, "*") {
+			pointerMethods[name] = append(pointerMethods[name], signature)
+		} else {
+			methods[name] = append(methods[name], signature)
+		}
+	}
+	return methods, pointerMethods
+}
+
+// interfaceMembers returns the methods of an interface type and the
+// interfaces and type constraints it embeds
+func interfaceMembers(iface *ast.InterfaceType) ([]MethodSignature, []string) {
+	methods := []MethodSignature{}
+	embedded := []string{}
+	for _, field := range iface.Methods.List {
+		fnType, isMethod := field.Type.(*ast.FuncType)
+		if !isMethod || len(field.Names) == 0 {
+			embedded = append(embedded, ExtractTypeString(field.Type))
+			continue
+		}
+		for _, name := range field.Names {
+			methods = append(methods, MethodSignature{
+				Name:       name.Name,
+				Parameters: extractParameters(fnType.Params),
+				Returns:    extractReturnTypes(fnType.Results),
+				DocString:  extractDocstring(field.Doc),
+			})
+		}
+	}
+	return methods, embedded
+}
+
+// extractInterfaces returns the interface type declarations in the file
+func extractInterfaces(file *ast.File, fSet *token.FileSet, annotationPrefixes []string) []InterfaceInfo {
+	result := []InterfaceInfo{}
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+			iface, ok := typeSpec.Type.(*ast.InterfaceType)
+			if !ok {
+				continue
+			}
+
+			doc := typeSpecDoc(genDecl, typeSpec)
+			info := InterfaceInfo{
+				Name:        typeSpec.Name.Name,
+				Exported:    typeSpec.Name.IsExported(),
+				StartLine:   fSet.Position(typeSpec.Pos()).Line,
+				EndLine:     fSet.Position(typeSpec.End()).Line,
+				IsAlias:     typeSpec.Assign.IsValid(),
+				DocString:   extractDocstring(doc),
+				Annotations: extractAnnotations(doc, annotationPrefixes),
+			}
+			info.Methods, info.Embedded = interfaceMembers(iface)
+			result = append(result, info)
+		}
+	}
+	return result
+}
+
+// signatureKey renders a method's parameter and result types, ignoring
+// names, so two signatures can be compared
+func signatureKey(fn *ast.FuncType) string {
+	types := func(fields *ast.FieldList) []string {
+		list := []string{}
+		if fields == nil {
+			return list
+		}
+		for _, field := range fields.List {
+			for i := 0; i < max(1, len(field.Names)); i++ {
+				list = append(list, ExtractTypeString(field.Type))
+			}
+		}
+		return list
+	}
+	return "(" + strings.Join(types(fn.Params), ", ") + ") (" + strings.Join(types(fn.Results), ", ") + ")"
+}
+
+// interfaceMethods returns the method set an interface declared in the file
+// requires, following embedded interfaces that are declared in the file too.
+// It reports false when the set can't be known from the file alone: an
+// embedded interface from elsewhere, a type constraint or a generic interface.
+func interfaceMethods(name string, interfaces map[string]*ast.TypeSpec, visiting map[string]bool) (map[string]string, bool) {
+	spec, ok := interfaces[name]
+	if !ok || spec.TypeParams != nil || visiting[name] {
+		return nil, false
+	}
+	visiting[name] = true
+	defer delete(visiting, name)
+
+	methods := make(map[string]string)
+	for _, field := range spec.Type.(*ast.InterfaceType).Methods.List {
+		if fnType, ok := field.Type.(*ast.FuncType); ok && len(field.Names) > 0 {
+			for _, method := range field.Names {
+				methods[method.Name] = signatureKey(fnType)
+			}
+			continue
+		}
+
+		ident, ok := field.Type.(*ast.Ident)
+		if !ok {
+			return nil, false
+		}
+		embedded, ok := interfaceMethods(ident.Name, interfaces, visiting)
+		if !ok {
+			return nil, false
+		}
+		for method, key := range embedded {
+			if existing, ok := methods[method]; ok && existing != key {
+				return nil, false
+			}
+			methods[method] = key
+		}
+	}
+	return methods, true
+}
+
+// detectImplements fills in which of the file's interfaces each of its structs
+// satisfies. Only methods declared in the file on the struct itself count;
+// methods promoted from embedded fields aren't followed. Empty interfaces and
+// generic structs are left out.
+func detectImplements(file *ast.File, structs []StructInfo) {
+	type method struct {
+		key     string
+		pointer bool
+	}
+
+	interfaces := make(map[string]*ast.TypeSpec)
+	var names []string
+	generic := make(map[string]bool)
+	methodSets := make(map[string]map[string]method)
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				if typeSpec.TypeParams != nil {
+					generic[typeSpec.Name.Name] = true
+				}
+				if _, ok := typeSpec.Type.(*ast.InterfaceType); ok {
+					interfaces[typeSpec.Name.Name] = typeSpec
+					names = append(names, typeSpec.Name.Name)
+				}
+			}
+		case *ast.FuncDecl:
+			if d.Recv == nil || len(d.Recv.List) == 0 {
+				continue
+			}
+			recv := d.Recv.List[0].Type
+			base := embeddedTypeName(recv)
+			if methodSets[base] == nil {
+				methodSets[base] = make(map[string]method)
+			}
+			_, pointer := recv.(*ast.StarExpr)
+			methodSets[base][d.Name.Name] = method{key: signatureKey(d.Type), pointer: pointer}
+		}
+	}
+
+	required := make(map[string]map[string]string)
+	for _, name := range names {
+		if methods, ok := interfaceMethods(name, interfaces, make(map[string]bool)); ok && len(methods) > 0 {
+			required[name] = methods
+		}
+	}
+
+	for i := range structs {
+		st := &structs[i]
+		if st.IsAlias || generic[st.Name] {
+			continue
+		}
+		methodSet := methodSets[st.Name]
+		for _, name := range names {
+			methods, ok := required[name]
+			if !ok {
+				continue
+			}
+			satisfied, needsPointer := true, false
+			for methodName, key := range methods {
+				m, ok := methodSet[methodName]
+				if !ok || m.key != key {
+					satisfied = false
+					break
+				}
+				needsPointer = needsPointer || m.pointer
+			}
+			switch {
+			case !satisfied:
+			case needsPointer:
+				st.PointerImplements = append(st.PointerImplements, name)
+			default:
+				st.Implements = append(st.Implements, name)
+			}
+		}
+	}
+}
+
+// extractExports returns every exported identifier declared at the top level of
+// the file, in source order
+func extractExports(file *ast.File, fSet *token.FileSet) []Export {
+	exports := []Export{}
+	add := func(ident *ast.Ident, kind string, receiver string) {
+		if ident.IsExported() {
+			exports = append(exports, Export{
+				Name:     ident.Name,
+				Kind:     kind,
+				Receiver: receiver,
+				Line:     fSet.Position(ident.Pos()).Line,
+			})
+		}
+	}
+
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				add(d.Name, "method", embeddedTypeName(d.Recv.List[0].Type))
+			} else {
+				add(d.Name, "function", "")
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch sp := spec.(type) {
+				case *ast.TypeSpec:
+					add(sp.Name, "type", "")
+				case *ast.ValueSpec:
+					kind := "var"
+					if d.Tok == token.CONST {
+						kind = "const"
+					}
+					for _, name := range sp.Names {
+						add(name, kind, "")
+					}
+				}
+			}
+		}
+	}
+	return exports
+}
+
+// PlaygroundSourceURL translates a Go playground share link such as
+// https://go.dev/play/p/abc123 into the URL serving its raw source
+func PlaygroundSourceURL(link string) (string, bool) {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", false
+	}
+
+	var id string
+	switch u.Host {
+	case "play.golang.org", "play.golang.com":
+		id = strings.TrimPrefix(u.Path, "/p/")
+	case "go.dev", "www.go.dev":
+		id = strings.TrimPrefix(u.Path, "/play/p/")
+	default:
+		return "", false
+	}
+	id = strings.TrimSuffix(id, ".go")
+	if id == "" || id == u.Path || strings.Contains(id, "/") {
+		return "", false
+	}
+	return "https://play.golang.org/p/" + id + ".go", true
+}
+
+// fetchPlaygroundSource downloads the source behind a playground share link
+func fetchPlaygroundSource(sourceURL string) ([]byte, error) {
+	client := http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Get(sourceURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", sourceURL, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// evalConstExpr folds a constant expression using the constants known so far.
+// The result has kind constant.Unknown when it can't be evaluated.
+func evalConstExpr(expr ast.Expr, iota int64, known map[string]constant.Value) constant.Value {
+	unknown := constant.MakeUnknown()
+
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		return constant.MakeFromLiteral(e.Value, e.Kind, 0)
+
+	case *ast.Ident:
+		switch e.Name {
+		case "iota":
+			return constant.MakeInt64(iota)
+		case "true":
+			return constant.MakeBool(true)
+		case "false":
+			return constant.MakeBool(false)
+		}
+		if value, ok := known[e.Name]; ok {
+			return value
+		}
+		return unknown
+
+	case *ast.ParenExpr:
+		return evalConstExpr(e.X, iota, known)
+
+	case *ast.UnaryExpr:
+		x := evalConstExpr(e.X, iota, known)
+		switch {
+		case (e.Op == token.ADD || e.Op == token.SUB) && isNumericConst(x),
+			e.Op == token.XOR && x.Kind() == constant.Int,
+			e.Op == token.NOT && x.Kind() == constant.Bool:
+			return constant.UnaryOp(e.Op, x, 0)
+		}
+		return unknown
+
+	case *ast.BinaryExpr:
+		x := evalConstExpr(e.X, iota, known)
+		y := evalConstExpr(e.Y, iota, known)
+		if x.Kind() == constant.Unknown || y.Kind() == constant.Unknown {
+			return unknown
+		}
+		if e.Op == token.SHL || e.Op == token.SHR {
+			// Both sides may be untyped floats with integer values, as in
+			// 1 << 2.0
+			x, y = constant.ToInt(x), constant.ToInt(y)
+			if x.Kind() != constant.Int || y.Kind() != constant.Int || constant.Sign(y) < 0 {
+				return unknown
+			}
+			shift, ok := constant.Uint64Val(y)
+			if !ok || shift > 1024 {
+				return unknown
+			}
+			return constant.Shift(x, e.Op, uint(shift))
+		}
+		if !constOperandsAllow(e.Op, x, y) {
+			return unknown
+		}
+
+		switch e.Op {
+		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+			return constant.MakeBool(constant.Compare(x, e.Op, y))
+		case token.QUO, token.REM:
+			if constant.Sign(y) == 0 {
+				return unknown
+			}
+		}
+
+		op := e.Op
+		if op == token.QUO && x.Kind() == constant.Int && y.Kind() == constant.Int {
+			// Integer division, the same trick go/types uses
+			op = token.QUO_ASSIGN
+		}
+		return constant.BinaryOp(x, op, y)
+
+	case *ast.CallExpr:
+		ident, ok := e.Fun.(*ast.Ident)
+		if !ok || len(e.Args) != 1 {
+			return unknown
+		}
+		arg := evalConstExpr(e.Args[0], iota, known)
+		if ident.Name == "len" {
+			if arg.Kind() != constant.String {
+				return unknown
+			}
+			return constant.MakeInt64(int64(len(constant.StringVal(arg))))
+		}
+		// Anything else with a single argument is treated as a conversion
+		return convertConst(ident.Name, arg)
+	}
+	return unknown
+}
+
+// isNumericConst reports whether value is an integer, float or complex
+// constant
+func isNumericConst(value constant.Value) bool {
+	switch value.Kind() {
+	case constant.Int, constant.Float, constant.Complex:
+		return true
+	}
+	return false
+}
+
+// constOperandsAllow reports whether the binary operator op, other than a
+// shift, is defined on constants x and y. Numbers mix freely, as untyped
+// constants do, but never with strings or booleans; %, &, |, ^ and &^ need
+// integers and only == and != compare booleans and complex numbers.
+func constOperandsAllow(op token.Token, x, y constant.Value) bool {
+	switch {
+	case isNumericConst(x) && isNumericConst(y):
+		switch op {
+		case token.ADD, token.SUB, token.MUL, token.QUO, token.EQL, token.NEQ:
+			return true
+		case token.REM, token.AND, token.OR, token.XOR, token.AND_NOT:
+			return x.Kind() == constant.Int && y.Kind() == constant.Int
+		case token.LSS, token.LEQ, token.GTR, token.GEQ:
+			return x.Kind() != constant.Complex && y.Kind() != constant.Complex
+		}
+	case x.Kind() == constant.String && y.Kind() == constant.String:
+		switch op {
+		case token.ADD, token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+			return true
+		}
+	case x.Kind() == constant.Bool && y.Kind() == constant.Bool:
+		switch op {
+		case token.LAND, token.LOR, token.EQL, token.NEQ:
+			return true
+		}
+	}
+	return false
+}
+
+// intBits gives the size of each predeclared integer type, with 0 for the
+// platform dependent ones, which are taken to be 64 bits wide
+var intBits = map[string]struct {
+	bits   uint
+	signed bool
+}{
+	"int": {0, true}, "int8": {8, true}, "int16": {16, true}, "int32": {32, true}, "int64": {64, true}, "rune": {32, true},
+	"uint": {0, false}, "uint8": {8, false}, "uint16": {16, false}, "uint32": {32, false}, "uint64": {64, false},
+	"uintptr": {0, false}, "byte": {8, false},
+}
+
+// convertConst converts a folded constant to the named type the way a Go
+// conversion would, returning an unknown value when the conversion isn't a
+// valid constant one. Types that aren't predeclared are assumed to keep the
+// value's kind, since their underlying type isn't known here.
+func convertConst(typeName string, value constant.Value) constant.Value {
+	unknown := constant.MakeUnknown()
+	if value.Kind() == constant.Unknown {
+		return unknown
+	}
+
+	if size, ok := intBits[typeName]; ok {
+		value = constant.ToInt(value)
+		if value.Kind() != constant.Int {
+			return unknown
+		}
+		bits := size.bits
+		if bits == 0 {
+			bits = 64
+		}
+		limit := constant.Shift(constant.MakeInt64(1), token.SHL, bits)
+		low := constant.MakeInt64(0)
+		if size.signed {
+			limit = constant.Shift(constant.MakeInt64(1), token.SHL, bits-1)
+			low = constant.UnaryOp(token.SUB, limit, 0)
+		}
+		if constant.Compare(value, token.LSS, low) || constant.Compare(value, token.GEQ, limit) {
+			return unknown
+		}
+		return value
+	}
+
+	switch typeName {
+	case "float32", "float64":
+		value = constant.ToFloat(value)
+		if value.Kind() != constant.Float {
+			return unknown
+		}
+		return value
+	case "complex64", "complex128":
+		value = constant.ToComplex(value)
+		if value.Kind() != constant.Complex {
+			return unknown
+		}
+		return value
+	case "string":
+		switch value.Kind() {
+		case constant.String:
+			return value
+		case constant.Int:
+			// string(65) is "A", and out of range code points become U+FFFD
+			r, ok := constant.Int64Val(value)
+			if !ok || r < 0 || r > unicode.MaxRune {
+				r = unicode.ReplacementChar
+			}
+			return constant.MakeString(string(rune(r)))
+		}
+		return unknown
+	case "bool":
+		if value.Kind() != constant.Bool {
+			return unknown
+		}
+		return value
+	}
+	return value
+}
+
+// constantString renders a folded constant, leaving strings unquoted
+func constantString(value constant.Value) string {
+	switch value.Kind() {
+	case constant.Unknown:
+		return ""
+	case constant.String:
+		return constant.StringVal(value)
+	case constant.Float:
+		f, _ := constant.Float64Val(value)
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	case constant.Int, constant.Bool:
+		return value.ExactString()
+	}
+	return value.String()
+}
+
+// extractConstants returns the package-level constants with their values.
+// Specs without a value repeat the previous expression as Go does, so iota
+// blocks are evaluated member by member.
+func extractConstants(file *ast.File, fSet *token.FileSet) []ValueInfo {
+	type pendingConst struct {
+		index int
+		expr  ast.Expr
+		iota  int64
+	}
+
+	constants := []ValueInfo{}
+	var pending []pendingConst
+	group := -1
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.CONST {
+			continue
+		}
+		group++
+
+		var values []ast.Expr
+		var valueType string
+		for i, spec := range genDecl.Specs {
+			valueSpec := spec.(*ast.ValueSpec)
+			if len(valueSpec.Values) > 0 {
+				values = valueSpec.Values
+				valueType = ""
+				if valueSpec.Type != nil {
+					valueType = ExtractTypeString(valueSpec.Type)
+				}
+			}
+			for j, name := range valueSpec.Names {
+				info := ValueInfo{
+					Name:      name.Name,
+					Type:      valueType,
+					Line:      fSet.Position(name.Pos()).Line,
+					Group:     group,
+					Grouped:   genDecl.Lparen.IsValid(),
+					DocString: valueSpecDoc(genDecl, valueSpec),
+				}
+				if j < len(values) {
+					info.Value = nodeString(fSet, values[j])
+					pending = append(pending, pendingConst{index: len(constants), expr: values[j], iota: int64(i)})
+				}
+				constants = append(constants, info)
+			}
+		}
+	}
+
+	// Constants may refer to ones declared later in the file, so keep folding
+	// until a pass makes no progress
+	known := make(map[string]constant.Value)
+	done := make(map[int]bool)
+	for progress := true; progress; {
+		progress = false
+		for _, p := range pending {
+			if done[p.index] {
+				continue
+			}
+			value := evalConstExpr(p.expr, p.iota, known)
+			// A declared type converts the value, so const F float64 = 3
+			// divides like a float
+			if valueType := constants[p.index].Type; valueType != "" {
+				value = convertConst(valueType, value)
+			}
+			if value.Kind() == constant.Unknown {
+				continue
+			}
+			if name := constants[p.index].Name; name != "_" {
+				known[name] = value
+			}
+			constants[p.index].EvaluatedValue = constantString(value)
+			done[p.index] = true
+			progress = true
+		}
+	}
+	return constants
+}
+
+// ModifiedAfter reports whether the file's modification time is after since
+func ModifiedAfter(path string, since time.Time) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	return info.ModTime().After(since), nil
+}
+
+// CanonicalizeFileInfo sorts everything in info whose order isn't already
+// fixed by the source, so repeated runs produce identical output
+func CanonicalizeFileInfo(info *FileInfo) {
+	sort.SliceStable(info.Functions, func(i, j int) bool {
+		a, b := info.Functions[i], info.Functions[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Receiver != b.Receiver {
+			return a.Receiver < b.Receiver
+		}
+		return a.StartLine < b.StartLine
+	})
+	for i := range info.Functions {
+		sort.Strings(info.Functions[i].Calls)
+		sort.Strings(info.Functions[i].ExternalCalls)
+	}
+	sort.Strings(info.Imports)
+}
+
+// extractVariables returns the package-level variables with their declared
+// types and initializer source
+func extractVariables(file *ast.File, fSet *token.FileSet) []ValueInfo {
+	variables := []ValueInfo{}
+	group := -1
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.VAR {
+			continue
+		}
+		group++
+
+		for _, spec := range genDecl.Specs {
+			valueSpec := spec.(*ast.ValueSpec)
+			valueType := ""
+			if valueSpec.Type != nil {
+				valueType = ExtractTypeString(valueSpec.Type)
+			}
+			for j, name := range valueSpec.Names {
+				info := ValueInfo{
+					Name:      name.Name,
+					Type:      valueType,
+					Line:      fSet.Position(name.Pos()).Line,
+					Group:     group,
+					Grouped:   genDecl.Lparen.IsValid(),
+					DocString: valueSpecDoc(genDecl, valueSpec),
+				}
+				switch {
+				case j < len(valueSpec.Values):
+					info.Value = nodeString(fSet, valueSpec.Values[j])
+				case len(valueSpec.Values) == 1:
+					// var a, b = f() assigns every name from one call
+					info.Value = nodeString(fSet, valueSpec.Values[0])
+				}
+				variables = append(variables, info)
+			}
+		}
+	}
+	return variables
+}
+
+// StdinFilename is the synthetic name used for source read from stdin
+const StdinFilename = "<stdin>.go"
+
+// ParseBuildTags turns the -tags value into a set, nil when it is empty
+func ParseBuildTags(value string) map[string]bool {
+	if value == "" {
+		return nil
+	}
+	tags := make(map[string]bool)
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags[tag] = true
+		}
+	}
+	return tags
+}
+
+// knownOS and knownArch are the GOOS and GOARCH values go/build recognizes
+// in file name suffixes
+var (
+	knownOS = map[string]bool{
+		"aix": true, "android": true, "darwin": true, "dragonfly": true, "freebsd": true,
+		"hurd": true, "illumos": true, "ios": true, "js": true, "linux": true, "nacl": true,
+		"netbsd": true, "openbsd": true, "plan9": true, "solaris": true, "wasip1": true,
+		"windows": true, "zos": true,
+	}
+	knownArch = map[string]bool{
+		"386": true, "amd64": true, "amd64p32": true, "arm": true, "armbe": true, "arm64": true,
+		"arm64be": true, "loong64": true, "mips": true, "mipsle": true, "mips64": true,
+		"mips64le": true, "mips64p32": true, "mips64p32le": true, "ppc": true, "ppc64": true,
+		"ppc64le": true, "riscv": true, "riscv64": true, "s390": true, "s390x": true,
+		"sparc": true, "sparc64": true, "wasm": true,
+	}
+)
+
+// matchesFileSuffix reports whether the _GOOS, _GOARCH or _GOOS_GOARCH
+// suffix of a file name, if it has one, is satisfied by tags. As with
+// go/build, a _test suffix is looked through and a name made of the suffix
+// alone, like linux.go, isn't constrained.
+func matchesFileSuffix(name string, tags map[string]bool) bool {
+	name, _, _ = strings.Cut(filepath.Base(name), ".")
+	i := strings.Index(name, "_")
+	if i < 0 {
+		return true
+	}
+	parts := strings.Split(strings.TrimSuffix(name[i:], "_test"), "_")
+	n := len(parts)
+	if n >= 2 && knownOS[parts[n-2]] && knownArch[parts[n-1]] {
+		return tags[parts[n-2]] && tags[parts[n-1]]
+	}
+	if n >= 1 && (knownOS[parts[n-1]] || knownArch[parts[n-1]]) {
+		return tags[parts[n-1]]
+	}
+	return true
+}
+
+// MatchesBuildTags reports whether the file called name is built with tags:
+// its _GOOS and _GOARCH file name suffixes and the build constraints at the
+// top of its content must both be satisfied. //go:build takes precedence over
+// the legacy // +build lines, and a file without either always matches.
+func MatchesBuildTags(name string, content []byte, tags map[string]bool) (bool, error) {
+	if tags == nil {
+		return true, nil
+	}
+	if !matchesFileSuffix(name, tags) {
+		return false, nil
+	}
+
+	var goBuild constraint.Expr
+	var plusBuild []constraint.Expr
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		// Constraints must appear before the package clause
+		if !strings.HasPrefix(line, "//") {
+			break
+		}
+
+		switch {
+		case constraint.IsGoBuild(line):
+			expr, err := constraint.Parse(line)
+			if err != nil {
+				return false, err
+			}
+			goBuild = expr
+		case constraint.IsPlusBuild(line):
+			expr, err := constraint.Parse(line)
+			if err != nil {
+				return false, err
+			}
+			plusBuild = append(plusBuild, expr)
+		}
+	}
+
+	hasTag := func(tag string) bool { return tags[tag] }
+	if goBuild != nil {
+		return goBuild.Eval(hasTag), nil
+	}
+	for _, expr := range plusBuild {
+		if !expr.Eval(hasTag) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// generatedMarker matches the line that marks a file as generated, per the
+// convention described in go help generate
+var generatedMarker = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// ErrGenerated is returned by ParseTarget for a generated file it skipped
+// because of SkipGenerated
+var ErrGenerated = errors.New("generated file")
+
+// IsGenerated reports whether content carries the "// Code generated ... DO
+// NOT EDIT." marker. Like build constraints, it only counts before the
+// package clause.
+func IsGenerated(content []byte) bool {
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "//") {
+			return false
+		}
+		if generatedMarker.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadSource returns the content of a local file, a playground share link, or
+// stdin when target is "-"
+func ReadSource(target string) ([]byte, error) {
+	if target == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	if sourceURL, ok := PlaygroundSourceURL(target); ok {
+		return fetchPlaygroundSource(sourceURL)
+	}
+	return os.ReadFile(target)
+}
+
+// DirectoryRoot reports whether target names a directory to walk, either an
+// existing directory or a ./...-style pattern, and returns its root
+func DirectoryRoot(target string) (string, bool) {
+	if strings.HasSuffix(target, "...") {
+		root := strings.TrimSuffix(strings.TrimSuffix(target, "..."), "/")
+		if root == "" {
+			root = "."
+		}
+		return root, true
+	}
+	info, err := os.Stat(target)
+	if err != nil || !info.IsDir() {
+		return "", false
+	}
+	return target, true
+}
+
+// collectGoFiles returns the .go files under root, skipping vendor, testdata
+// and hidden directories. A non-zero since leaves out files not modified
+// after it.
+func collectGoFiles(root string, since time.Time) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != root && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+		if !since.IsZero() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if !info.ModTime().After(since) {
+				return nil
+			}
+		}
+		files = append(files, path)
+		return nil
+	})
+	return files, err
+}
+
+// ParseDirectory parses every Go file under root. Files are keyed by their
+// path relative to root, and a file that fails is recorded in Errors rather
+// than aborting the run.
+func ParseDirectory(fSet *token.FileSet, root string, since time.Time, opts Options) (MultiFileInfo, error) {
+	multi := MultiFileInfo{
+		SchemaVersion: SchemaVersion,
+		Files:         make(map[string]FileInfo),
+		Errors:        make(map[string]string),
+	}
+
+	files, err := collectGoFiles(root, since)
+	if err != nil {
+		return multi, err
+	}
+
+	sources := make([]sourceFile, 0, len(files))
+	for _, path := range files {
+		name, err := filepath.Rel(root, path)
+		if err != nil {
+			name = path
+		}
+		sources = append(sources, sourceFile{name: filepath.ToSlash(name), target: path})
+	}
+	parseSources(fSet, sources, opts, &multi)
+	return multi, nil
+}
+
+// sourceFile is one file to parse: the name it is reported under and the
+// target it is read from
+type sourceFile struct {
+	name   string
+	target string
+}
+
+// ParseTarget reads target, which may also be "-" for stdin or a playground
+// URL, and parses it reported as name. It reports false when the file's
+// build constraints exclude it, and returns ErrGenerated for a generated
+// file with opts.SkipGenerated set. A file that couldn't be parsed at all
+// comes back with its ParseErrors along with the error. With opts.Cache set,
+// files on disk are looked up in and saved to the cache; type resolution and
+// struct layout depend on the rest of the package, so they bypass the cache.
+func ParseTarget(fSet *token.FileSet, name, target string, opts Options) (FileInfo, bool, error) {
+	_, isURL := PlaygroundSourceURL(target)
+	cache := opts.Cache
+	if target == "-" || isURL || opts.ResolveTypes || opts.LayoutArch != "" {
+		cache = nil
+	}
+	if cache != nil {
+		if entry, ok := cache.get(name, target, opts); ok {
+			if opts.SkipGenerated && entry.Generated {
+				return FileInfo{}, false, ErrGenerated
+			}
+			return entry.Info, entry.Included, nil
+		}
+	}
+
+	content, err := ReadSource(target)
+	if err != nil {
+		return FileInfo{}, false, err
+	}
+	generated := IsGenerated(content)
+	if opts.SkipGenerated && generated {
+		return FileInfo{}, false, ErrGenerated
+	}
+	matches, err := MatchesBuildTags(name, content, opts.BuildTags)
+	if err != nil {
+		return FileInfo{}, false, err
+	}
+	if !matches {
+		if cache != nil {
+			cache.put(name, target, opts, cacheEntry{Generated: generated})
+		}
+		return FileInfo{}, false, nil
+	}
+
+	info, err := Parse(fSet, name, content, opts)
+	if err != nil {
+		return info, true, err
+	}
+	if opts.ResolveTypes {
+		if err := ResolveTypes(&info, target); err != nil {
+			return FileInfo{}, false, err
+		}
+	}
+	if opts.LayoutArch != "" {
+		if err := ResolveLayout(&info, target, opts.LayoutArch); err != nil {
+			return FileInfo{}, false, err
+		}
+	}
+	if cache != nil {
+		cache.put(name, target, opts, cacheEntry{Included: true, Generated: generated, Info: info})
+	}
+	return info, true, nil
+}
+
+// parseSources parses sources on a pool of opts.Jobs workers and records each
+// result in multi. Results are keyed by name, and skipped generated files
+// sorted, so the output doesn't depend on which worker finishes first. With
+// opts.OnFile set, parsed files are handed to it as they complete instead.
+func parseSources(fSet *token.FileSet, sources []sourceFile, opts Options, multi *MultiFileInfo) {
+	type result struct {
+		name     string
+		info     FileInfo
+		included bool
+		err      error
+	}
+
+	workers := opts.Jobs
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(sources) {
+		workers = len(sources)
+	}
+
+	work := make(chan sourceFile)
+	results := make(chan result)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for src := range work {
+				info, included, err := ParseTarget(fSet, src.name, src.target, opts)
+				results <- result{name: src.name, info: info, included: included, err: err}
+			}
+		}()
+	}
+	go func() {
+		for _, src := range sources {
+			work <- src
+		}
+		close(work)
+		wg.Wait()
+		close(results)
+	}()
+
+	for r := range results {
+		if errors.Is(r.err, ErrGenerated) {
+			multi.SkippedFiles = append(multi.SkippedFiles, r.name)
+			continue
+		}
+		if r.err != nil {
+			multi.Errors[r.name] = r.err.Error()
+			// An unparseable file is still reported, with just its errors
+			if len(r.info.ParseErrors) == 0 {
+				continue
+			}
+		}
+		switch {
+		case r.included && opts.OnFile != nil:
+			opts.OnFile(r.name, r.info)
+		case r.included:
+			multi.Files[r.name] = r.info
+		}
+	}
+	sort.Strings(multi.SkippedFiles)
+	multi.Packages = GroupPackages(multi.Files)
+}
+
+// GroupPackages groups files, keyed by slash-separated name, by directory and
+// package clause, ordered by directory and then package name. A directory
+// holding both a package and its external _test package yields two entries.
+// A file whose package clause couldn't be parsed belongs to no package and is
+// left out; its syntax errors are reported with the file.
+func GroupPackages(files map[string]FileInfo) []PackageInfo {
+	type key struct{ dir, name string }
+	byKey := make(map[key]*PackageInfo)
+	imports := make(map[key]map[string]bool)
+	for fileName, info := range files {
+		if info.Package == "" {
+			continue
+		}
+		k := key{path.Dir(fileName), info.Package}
+		pkg, ok := byKey[k]
+		if !ok {
+			pkg = &PackageInfo{Name: info.Package, Dir: k.dir, Files: []string{}, Imports: []string{}}
+			byKey[k] = pkg
+			imports[k] = make(map[string]bool)
+		}
+		pkg.Files = append(pkg.Files, fileName)
+		pkg.FunctionCount += len(info.Functions)
+		for _, imp := range info.ImportSpecs {
+			imports[k][imp.Path] = true
+		}
+	}
+
+	packages := make([]PackageInfo, 0, len(byKey))
+	for k, pkg := range byKey {
+		for imp := range imports[k] {
+			pkg.Imports = append(pkg.Imports, imp)
+		}
+		sort.Strings(pkg.Imports)
+		sort.Strings(pkg.Files)
+
+		var functions []FunctionInfo
+		pkgFiles := make([]FileInfo, len(pkg.Files))
+		for i, fileName := range pkg.Files {
+			pkgFiles[i] = files[fileName]
+			functions = append(functions, files[fileName].Functions...)
+		}
+		pkg.SingleCallerHelpers = findSingleCallerHelpers(functions)
+		pkg.EnumCoverage = packageEnumCoverage(pkgFiles)
+		packages = append(packages, *pkg)
+	}
+	sort.Slice(packages, func(i, j int) bool {
+		if packages[i].Dir != packages[j].Dir {
+			return packages[i].Dir < packages[j].Dir
+		}
+		return packages[i].Name < packages[j].Name
+	})
+	return packages
+}
+
+// LooksLikeImportPath reports whether target is better read as a package
+// import path, like net/http, than as a file: it doesn't exist on disk and
+// isn't stdin, a playground URL, a .go file or a relative or absolute path
+func LooksLikeImportPath(target string) bool {
+	if _, isURL := PlaygroundSourceURL(target); target == "-" || isURL || strings.HasSuffix(target, ".go") {
+		return false
+	}
+	if build.IsLocalImport(target) || filepath.IsAbs(target) {
+		return false
+	}
+	_, err := os.Stat(target)
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+// ParseImportPath locates the package with the given import path the way the
+// go command would from the current directory, module included, and parses
+// its non-test files. Files are keyed by import path and file name. Without
+// opts.BuildTags the files go/build selects for this platform are parsed;
+// with them, every non-test file is considered and filtered by those tags.
+func ParseImportPath(fSet *token.FileSet, importPath string, since time.Time, opts Options) (MultiFileInfo, error) {
+	multi := MultiFileInfo{
+		SchemaVersion: SchemaVersion,
+		Files:         make(map[string]FileInfo),
+		Errors:        make(map[string]string),
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return multi, err
+	}
+	bp, err := build.Default.Import(importPath, cwd, 0)
+	var noGo *build.NoGoError
+	if err != nil && !(errors.As(err, &noGo) && opts.BuildTags != nil) {
+		return multi, fmt.Errorf("resolving import path %s: %w", importPath, err)
+	}
+
+	names := append(append([]string{}, bp.GoFiles...), bp.CgoFiles...)
+	if opts.BuildTags != nil {
+		for _, name := range bp.IgnoredGoFiles {
+			if !strings.HasSuffix(name, "_test.go") {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	var sources []sourceFile
+	for _, name := range names {
+		path := filepath.Join(bp.Dir, name)
+		if !since.IsZero() {
+			modified, err := ModifiedAfter(path, since)
+			if err != nil {
+				return multi, err
+			}
+			if !modified {
+				continue
+			}
+		}
+		sources = append(sources, sourceFile{name: bp.ImportPath + "/" + name, target: path})
+	}
+	parseSources(fSet, sources, opts, &multi)
+	return multi, nil
+}
+
+// ParseFiles parses each target into one MultiFileInfo keyed by its name as
+// given. Directory targets are walked and their files keyed under the
+// directory, and a failure on one target is recorded in Errors rather than
+// ending the run.
+func ParseFiles(fSet *token.FileSet, targets []string, since time.Time, opts Options) MultiFileInfo {
+	multi := MultiFileInfo{
+		SchemaVersion: SchemaVersion,
+		Files:         make(map[string]FileInfo),
+		Errors:        make(map[string]string),
+	}
+
+	var sources []sourceFile
+	for _, target := range targets {
+		if root, ok := DirectoryRoot(target); ok {
+			dir, err := ParseDirectory(fSet, root, since, opts)
+			if err != nil {
+				multi.Errors[target] = err.Error()
+				continue
+			}
+			for name, info := range dir.Files {
+				multi.Files[filepath.ToSlash(filepath.Join(root, name))] = info
+			}
+			for name, msg := range dir.Errors {
+				multi.Errors[filepath.ToSlash(filepath.Join(root, name))] = msg
+			}
+			for _, name := range dir.SkippedFiles {
+				multi.SkippedFiles = append(multi.SkippedFiles, filepath.ToSlash(filepath.Join(root, name)))
+			}
+			continue
+		}
+
+		name := target
+		if target == "-" {
+			name = StdinFilename
+		}
+
+		_, isURL := PlaygroundSourceURL(target)
+		if target != "-" && !isURL && !since.IsZero() {
+			modified, err := ModifiedAfter(target, since)
+			if err != nil {
+				multi.Errors[name] = err.Error()
+				continue
+			}
+			if !modified {
+				continue
+			}
+		}
+
+		sources = append(sources, sourceFile{name: name, target: target})
+	}
+	parseSources(fSet, sources, opts, &multi)
+	return multi
+}
+
+// AllFunctions returns the functions of every file, ordered by file name
+func AllFunctions(multi MultiFileInfo) []FunctionInfo {
+	names := make([]string, 0, len(multi.Files))
+	for name := range multi.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var functions []FunctionInfo
+	for _, name := range names {
+		functions = append(functions, multi.Files[name].Functions...)
+	}
+	return functions
+}
+
+// includeFunction decides whether a function is emitted. Every declared
+// function is by default; with ExportedOnly only exported ones are, except
+// init which always runs at package load and is kept regardless. A method is
+// judged by its own name, so an exported method of an unexported type is
+// kept since it can still be reached through an interface. Only and a Filter
+// then narrow the result further.
+func includeFunction(fn *ast.FuncDecl, opts Options) bool {
+	if opts.ExportedOnly && !fn.Name.IsExported() && !(fn.Recv == nil && fn.Name.Name == "init") {
+		return false
+	}
+	isMethod := fn.Recv != nil && len(fn.Recv.List) > 0
+	if (opts.Only == "methods" && !isMethod) || (opts.Only == "functions" && isMethod) {
+		return false
+	}
+	if opts.Filter == nil {
+		return true
+	}
+
+	// Methods match as either Method or Receiver.Method
+	if opts.Filter.MatchString(fn.Name.Name) {
+		return true
+	}
+	if isMethod {
+		if typeName := embeddedTypeName(fn.Recv.List[0].Type); typeName != "" {
+			return opts.Filter.MatchString(typeName + "." + fn.Name.Name)
+		}
+	}
+	return false
+}
+
+// ParseFile reads the Go source file at path and extracts its FileInfo using
+// the default options
+func ParseFile(path string) (*FileInfo, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSource(path, src)
+}
+
+// ParseSource extracts the FileInfo for Go source held in memory, reported as
+// name, using the default options. As with Parse, syntax errors are listed
+// in ParseErrors, and the error that comes when nothing could be recovered is
+// returned with a FileInfo holding just those.
+func ParseSource(name string, src []byte) (*FileInfo, error) {
+	info, err := Parse(token.NewFileSet(), name, src, DefaultOptions())
+	return &info, err
+}
+
+// ParseFileSource extracts the FileInfo for one Go source file using the
+// default options.
+//
+// Deprecated: ParseFile used to have this signature; use ParseSource.
+func ParseFileSource(filename string, src []byte) (FileInfo, error) {
+	return Parse(token.NewFileSet(), filename, src, DefaultOptions())
+}
+
+// Parse extracts the FileInfo for one Go source file, adding it to fSet.
+// Syntax errors are listed in ParseErrors and whatever parsed is still
+// extracted. Only when nothing could be recovered, not even the package
+// clause, is an error returned, together with a FileInfo holding just the
+// ParseErrors.
+func Parse(fSet *token.FileSet, filename string, content []byte, opts Options) (FileInfo, error) {
+	sourceLines := strings.Split(string(content), "\n")
+
+	// Keep going past syntax errors and extract whatever the parser recovered
+	node, err := parser.ParseFile(fSet, filename, content, parser.ParseComments|parser.AllErrors)
+	parseErrors := []string{}
+	if list, ok := err.(scanner.ErrorList); ok {
+		for _, e := range list {
+			parseErrors = append(parseErrors, e.Error())
+		}
+	} else if err != nil {
+		return FileInfo{}, err
+	}
+	if node == nil || !node.Package.IsValid() {
+		// Without a package clause nothing was recovered
+		return FileInfo{
+			SchemaVersion: SchemaVersion,
+			Filename:      filename,
+			Functions:     []FunctionInfo{},
+			ParseErrors:   parseErrors,
+		}, err
+	}
+
+	fileInfo := FileInfo{
+		SchemaVersion: SchemaVersion,
+		Filename:      filename,
+		Package:       node.Name.Name,
+		Functions:     []FunctionInfo{},
+		Imports:       extractImports(node),
+		ImportSpecs:   extractImportSpecs(node, fSet),
+		ParseErrors:   parseErrors,
+	}
+
+	localTypes := collectLocalTypes(node)
+	enumMembers := collectEnumMembers(node, localTypes)
+	packageVars := collectPackageVars(node)
+	importNames := importLocalNames(node)
+	genericFuncs := collectGenericFuncs(node)
+	contextDotImported := false
+	for _, imp := range node.Imports {
+		if imp.Name != nil && imp.Name.Name == "." && strings.Trim(imp.Path.Value, "\"") == "context" {
+			contextDotImported = true
+		}
+	}
+
+	// init functions are numbered in source order whether or not they are
+	// emitted
+	initCount := 0
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.FuncDecl:
+			if isInitFunc(x) {
+				initCount++
+			}
+			if includeFunction(x, opts) {
+				startPos := fSet.Position(x.Pos())
+				endPos := fSet.Position(x.End())
+
+				receiver := ""
+				isMethod := false
+				if x.Recv != nil && len(x.Recv.List) > 0 {
+					isMethod = true
+					switch t := x.Recv.List[0].Type.(type) {
+					case *ast.Ident:
+						receiver = t.Name
+					case *ast.StarExpr:
+						switch base := t.X.(type) {
+						case *ast.Ident:
+							receiver = "*" + base.Name
+						case *ast.IndexExpr, *ast.IndexListExpr:
+							receiver = "*" + ExtractTypeString(base)
+						}
+					case *ast.IndexExpr, *ast.IndexListExpr:
+						receiver = ExtractTypeString(t)
+					}
+				}
+
+				errorfWrapped, errorfUnwrapped := countErrorfWrapping(x)
+				readsGlobals, writesGlobals := extractGlobalAccess(x, packageVars)
+
+				rawCode := ""
+				if startPos.Line > 0 && endPos.Line > 0 && startPos.Line <= len(sourceLines) && endPos.Line <= len(sourceLines) {
+					funcLines := sourceLines[startPos.Line-1 : endPos.Line]
+					rawCode = strings.Join(funcLines, "\n")
+				}
+
+				funcInfo := FunctionInfo{
+					Name:               x.Name.Name,
+					StartLine:          startPos.Line,
+					EndLine:            endPos.Line,
+					StartCol:           startPos.Column,
+					EndCol:             endPos.Column,
+					Parameters:         extractParameters(x.Type.Params),
+					ParameterNames:     extractFieldNames(x.Type.Params),
+					IsVariadic:         isVariadic(x.Type),
+					Returns:            extractReturnTypes(x.Type.Results),
+					ReturnCount:        x.Type.Results.NumFields(),
+					ReturnNames:        extractFieldNames(x.Type.Results),
+					Calls:              extractCalls(x, genericFuncs),
+					IsMethod:           isMethod,
+					Receiver:           receiver,
+					ReceiverTypeParams: receiverTypeParams(x.Recv),
+					DocString:          extractDocstring(x.Doc),
+					DocStringRaw:       extractRawDocstring(x.Doc),
+					DocBlocks:          extractDocBlocks(x.Doc),
+					RawCode:            rawCode,
+					TypeParams:         extractTypeParams(x.Type.TypeParams),
+					Complexity:         calculateComplexity(x),
+					MaxNestingDepth:    calculateNestingDepth(x),
+					StatementCount:     countStatements(x),
+					BodyHash:           hashBody(x.Body),
+
+					StringConcatInLoop:  detectStringConcatInLoop(x),
+					Pragmas:             extractPragmas(x.Doc),
+					RecoverIgnoresValue: detectRecoverIgnoresValue(x),
+					CouldBeMethod:       detectCouldBeMethod(x, localTypes),
+					ReceiverUnused:      detectReceiverUnused(x),
+					NonExhaustiveSwitch: detectNonExhaustiveSwitch(x, enumMembers),
+					WrapsErrors:         errorfWrapped > 0,
+					ErrorfWrapped:       errorfWrapped,
+					ErrorfUnwrapped:     errorfUnwrapped,
+
+					AppendWithoutPrealloc: detectAppendWithoutPrealloc(x),
+					Defers:                extractDefers(x, fSet),
+					SignatureTypeCount:    countSignatureTypes(x.Type),
+					ReadsGlobals:          readsGlobals,
+					WritesGlobals:         writesGlobals,
+					Annotations:           extractAnnotations(x.Doc, opts.AnnotationPrefixes),
+					IsPure:                len(writesGlobals) == 0 && !detectSideEffects(x, importNames),
+				}
+				funcInfo.IsRecursive = detectRecursion(x, funcInfo.Calls)
+				funcInfo.TestKind = detectTestKind(x, importNames)
+				funcInfo.IsTest = funcInfo.TestKind != ""
+				funcInfo.MissingDoc = !funcInfo.IsTest && isExportedAPI(x) && funcInfo.DocString == ""
+				funcInfo.IsDeprecated, funcInfo.DeprecationNote = extractDeprecation(x.Doc)
+				funcInfo.ReturnsError, funcInfo.ErrorIsLast = detectErrorResults(x.Type.Results, opts.ErrorTypes)
+				funcInfo.TakesContext, funcInfo.ContextIsFirst = detectContextParams(x.Type.Params, importNames, contextDotImported)
+				funcInfo.HasDefer, funcInfo.HasGoroutine, funcInfo.HasPanic, funcInfo.GoCallSites = detectDeferGoPanic(x, fSet)
+				funcInfo.HasGoto, funcInfo.HasLabeledBranch, funcInfo.Labels = detectLabels(x)
+				funcInfo.UsedImports = extractUsedImports(x, importNames)
+				funcInfo.ExternalCalls = extractExternalCalls(x, importNames)
+				if isInitFunc(x) {
+					funcInfo.IsInit, funcInfo.InitOrder = true, initCount
+				}
+
+				fileInfo.Functions = append(fileInfo.Functions, funcInfo)
+			}
+		}
+		return true
+	})
+
+	computeFanMetrics(fileInfo.Functions)
+	fileInfo.SingleCallerHelpers = findSingleCallerHelpers(fileInfo.Functions)
+	fileInfo.CallGraph = buildCallGraph(fileInfo.Functions)
+	fileInfo.Structs = extractStructs(node, fSet, opts.AnnotationPrefixes)
+	fileInfo.Interfaces = extractInterfaces(node, fSet, opts.AnnotationPrefixes)
+	detectImplements(node, fileInfo.Structs)
+	fileInfo.Types = extractTypes(node, fSet, opts.AnnotationPrefixes)
+	fileInfo.Exports = extractExports(node, fSet)
+	fileInfo.Constants = extractConstants(node, fSet)
+	fileInfo.Variables = extractVariables(node, fSet)
+	fileInfo.Switches = extractSwitches(node, fSet)
+	fileInfo.EnumCoverage = buildEnumCoverage(fileInfo.Switches, enumMembers)
+	fileInfo.Markers = extractMarkers(node, fSet)
+	fileInfo.Closures = extractClosures(node, fSet)
+	fileInfo.Metrics = computeMetrics(node, fSet, sourceLines)
+	fileInfo.Metrics.FunctionCount = len(fileInfo.Functions)
+	fileInfo.Summary = summarizeComplexity(fileInfo.Functions)
+	fileInfo.UnknownTypes = collectUnknownTypes(node, fSet)
+	if opts.FindUnused {
+		fileInfo.UnusedFunctions = findUnusedFunctions(node)
+		fileInfo.UnusedNote = unusedNote
+	}
+
+	return fileInfo, nil
+}
@@ -0,0 +1,1075 @@
+package goparser
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// parseSource parses src with the default options, failing the test on error
+func parseSource(t *testing.T, src string) FileInfo {
+	t.Helper()
+	info, err := Parse(token.NewFileSet(), "test.go", []byte(src), DefaultOptions())
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return info
+}
+
+// findFunction returns the function named name, failing the test without one
+func findFunction(t *testing.T, info FileInfo, name string) FunctionInfo {
+	t.Helper()
+	for _, fn := range info.Functions {
+		if fn.Name == name {
+			return fn
+		}
+	}
+	t.Fatalf("function %s not found", name)
+	return FunctionInfo{}
+}
+
+func TestReturnTypes(t *testing.T) {
+	info := parseSource(t, `package p
+
+type T struct{}
+
+func Slice() []byte { return nil }
+func Pointer() *T { return nil }
+func Map() map[string]int { return nil }
+func Func() func() error { return nil }
+func Chan() <-chan T { return nil }
+func Generic() List[T] { return List[T]{} }
+func Pair() (n int, err error) { return 0, nil }
+`)
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Slice", "[]byte"},
+		{"Pointer", "*T"},
+		{"Map", "map[string]int"},
+		{"Func", "func() (error)"},
+		{"Chan", "<-chan T"},
+		{"Generic", "List[T]"},
+		{"Pair", "int, error"},
+	}
+	for _, tt := range tests {
+		if got := findFunction(t, info, tt.name).Returns; got != tt.want {
+			t.Errorf("%s returns %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStrictAcceptsCompositeReturns(t *testing.T) {
+	info := parseSource(t, `package p
+
+type T struct{}
+
+func Slice() []byte { return nil }
+func Pointer() *T { return nil }
+func Map() map[string]*T { return nil }
+`)
+	var report strings.Builder
+	if count := ReportUnknownTypes(&report, []FileInfo{info}); count != 0 {
+		t.Errorf("ReportUnknownTypes found %d unknown types:\n%s", count, report.String())
+	}
+}
+
+func TestUnknownTypesArePerFile(t *testing.T) {
+	// The missing map key parses as a BadExpr
+	bad := parseSource(t, "package p\n\nvar x [2]int\nfunc F(a map[]int) {}\n")
+	if len(bad.UnknownTypes) == 0 {
+		t.Fatal("expected an unknown type for the malformed map type")
+	}
+	if !strings.Contains(bad.UnknownTypes[0], "test.go:4:") {
+		t.Errorf("unknown type %q doesn't point at line 4", bad.UnknownTypes[0])
+	}
+
+	good := parseSource(t, "package p\n\nfunc F(a []int) {}\n")
+	if len(good.UnknownTypes) != 0 {
+		t.Errorf("a later parse picked up unknown types %q", good.UnknownTypes)
+	}
+}
+
+func TestPackageCallsAreNotLocalEdges(t *testing.T) {
+	info := parseSource(t, `package p
+
+import (
+	"errors"
+	str "strings"
+)
+
+func New() error { return errors.New("p") }
+
+func Join() string { return str.Join(nil, "") }
+
+func Make() error {
+	errors := []error{New()}
+	_ = errors.New
+	return New()
+}
+`)
+
+	newFn := findFunction(t, info, "New")
+	if want := []string{"errors.New"}; !slices.Equal(newFn.ExternalCalls, want) {
+		t.Errorf("New external calls = %q, want %q", newFn.ExternalCalls, want)
+	}
+	if want := []string{"str.Join"}; !slices.Equal(findFunction(t, info, "Join").ExternalCalls, want) {
+		t.Errorf("Join external calls = %q, want %q", findFunction(t, info, "Join").ExternalCalls, want)
+	}
+	if newFn.IsRecursive || newFn.FanOut != 0 || newFn.FanIn != 1 {
+		t.Errorf("New: recursive %v, fan-out %d, fan-in %d; want false, 0, 1", newFn.IsRecursive, newFn.FanOut, newFn.FanIn)
+	}
+	if want := []Edge{{From: "Make", To: "New"}}; !slices.Equal(info.CallGraph, want) {
+		t.Errorf("call graph = %+v, want %+v", info.CallGraph, want)
+	}
+
+	topo := BuildTopoOrder(info.Functions)
+	if !topo.Acyclic {
+		t.Errorf("topo order reports cycles %q", topo.Cycles)
+	}
+	if _, cycles := OrderTopologically(info.Functions); len(cycles) != 0 {
+		t.Errorf("OrderTopologically reports cycles %q", cycles)
+	}
+
+	matrix := BuildCallMatrix(info.Functions)
+	for i, name := range matrix.Functions {
+		if matrix.Matrix[i][i] != 0 {
+			t.Errorf("call matrix has a self edge for %s", name)
+		}
+	}
+
+	if dot := RenderDOT(info.Functions); strings.Contains(dot, `"New" -> "New"`) || strings.Contains(dot, `"Join" -> "Join"`) {
+		t.Errorf("DOT output has a self edge:\n%s", dot)
+	}
+}
+
+func TestCallsInstantiateOnlyLocalGenerics(t *testing.T) {
+	info := parseSource(t, `package p
+
+import "slices"
+
+func Map[T, U any](xs []T, f func(T) U) []U { return nil }
+func First[T any](xs []T) T { var zero T; return zero }
+
+func Use(handlers []func(), byName map[string]func() int, xs []int) {
+	Map[int, string](xs, nil)
+	First[int](xs)
+	handlers[0]()
+	handlers[len(xs)-1]()
+	byName["a"]()
+	slices.Index[[]int](xs, 1)
+}
+`)
+	want := []string{"Map[int,string]", "First[int]", "len"}
+	if got := findFunction(t, info, "Use").Calls; !slices.Equal(got, want) {
+		t.Errorf("calls = %q, want %q", got, want)
+	}
+}
+
+func TestConstConversions(t *testing.T) {
+	info := parseSource(t, `package p
+
+type Level int
+
+const (
+	Half      = float64(3) / 2
+	Whole     = int(7) / 2
+	Truncated = int(3.5)
+	Overflow  = int8(200)
+	Byte      = byte(255)
+	Letter    = string(rune(65))
+	Complex   = complex128(2)
+	Typed     float64 = 3
+	TypedHalf         = Typed / 2
+	Named             = Level(2) + 1
+	NotBool           = bool(1)
+)
+`)
+
+	tests := []struct {
+		name, want string
+	}{
+		{"Half", "1.5"},
+		{"Whole", "3"},
+		{"Truncated", ""},
+		{"Overflow", ""},
+		{"Byte", "255"},
+		{"Letter", "A"},
+		{"Complex", "(2 + 0i)"},
+		{"Typed", "3"},
+		{"TypedHalf", "1.5"},
+		{"Named", "3"},
+		{"NotBool", ""},
+	}
+	values := make(map[string]string)
+	for _, c := range info.Constants {
+		values[c.Name] = c.EvaluatedValue
+	}
+	for _, tt := range tests {
+		if got, ok := values[tt.name]; !ok || got != tt.want {
+			t.Errorf("%s evaluates to %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPackageSingleCallerHelpers(t *testing.T) {
+	files := map[string]FileInfo{
+		"pkg/a.go": parseSource(t, "package p\n\nfunc helper() {}\nfunc shared() {}\nfunc Run() { shared() }\n"),
+		"pkg/b.go": parseSource(t, "package p\n\nfunc Start() { helper(); shared() }\n"),
+		"pkg/c.go": parseSource(t, "package q\n\nfunc Other() { helper() }\n"),
+	}
+	if helpers := files["pkg/a.go"].SingleCallerHelpers; !slices.Equal(helpers, []string{"shared"}) {
+		t.Errorf("a.go alone has single-caller helpers %q, want [shared]", helpers)
+	}
+
+	packages := GroupPackages(files)
+	if len(packages) != 2 {
+		t.Fatalf("got %d packages, want 2", len(packages))
+	}
+	if got := packages[0].SingleCallerHelpers; packages[0].Name != "p" || !slices.Equal(got, []string{"helper"}) {
+		t.Errorf("package %s has single-caller helpers %q, want p with [helper]", packages[0].Name, got)
+	}
+}
+
+func TestPackageEnumCoverage(t *testing.T) {
+	files := map[string]FileInfo{
+		"pkg/kind.go": parseSource(t, `package p
+
+type Kind int
+
+const (
+	Small Kind = iota
+	Medium
+	Large
+)
+`),
+		"pkg/use.go": parseSource(t, `package p
+
+func Size(k Kind) int {
+	switch k {
+	case Small:
+		return 1
+	case Medium:
+		return 2
+	}
+	return 0
+}
+`),
+	}
+	if coverage := files["pkg/use.go"].EnumCoverage; len(coverage) != 0 {
+		t.Errorf("use.go alone reports enum coverage %+v", coverage)
+	}
+	if switches := files["pkg/use.go"].Switches; len(switches) != 1 || !slices.Equal(switches[0].Cases, []string{"Medium", "Small"}) {
+		t.Errorf("use.go switches = %+v, want one with Medium and Small", switches)
+	}
+
+	packages := GroupPackages(files)
+	if len(packages) != 1 {
+		t.Fatalf("got %d packages, want 1", len(packages))
+	}
+	want := []EnumCoverage{{
+		Type:      "Kind",
+		Switches:  1,
+		Handled:   []string{"Small", "Medium"},
+		Unhandled: []string{"Large"},
+	}}
+	got := packages[0].EnumCoverage
+	if len(got) != 1 || got[0].Type != want[0].Type || got[0].Switches != want[0].Switches ||
+		!slices.Equal(got[0].Handled, want[0].Handled) || !slices.Equal(got[0].Unhandled, want[0].Unhandled) {
+		t.Errorf("package enum coverage = %+v, want %+v", got, want)
+	}
+}
+
+func TestTypeAndInterfaceAnnotations(t *testing.T) {
+	info := parseSource(t, `package p
+
+// Phase is a lifecycle phase
+// +kubebuilder:validation:Enum=Pending;Running
+type Phase string
+
+type (
+	// Store persists objects
+	// @Service
+	Store interface {
+		Save() error
+	}
+)
+`)
+	if len(info.Types) != 2 {
+		t.Fatalf("got %d types, want 2", len(info.Types))
+	}
+	if want := []string{"+kubebuilder:validation:Enum=Pending;Running"}; !slices.Equal(info.Types[0].Annotations, want) {
+		t.Errorf("Phase annotations = %q, want %q", info.Types[0].Annotations, want)
+	}
+	if len(info.Interfaces) != 1 || !slices.Equal(info.Interfaces[0].Annotations, []string{"@Service"}) {
+		t.Fatalf("interfaces = %+v, want Store annotated @Service", info.Interfaces)
+	}
+	if !slices.Equal(info.Types[1].Annotations, []string{"@Service"}) {
+		t.Errorf("Store type annotations = %q, want [@Service]", info.Types[1].Annotations)
+	}
+}
+
+func TestMatchesBuildTags(t *testing.T) {
+	linux := map[string]bool{"linux": true, "amd64": true}
+	tests := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{"file.go", "package p\n", true},
+		{"file_linux.go", "package p\n", true},
+		{"file_windows.go", "package p\n", false},
+		{"dir/file_arm64.go", "package p\n", false},
+		{"file_linux_amd64.go", "package p\n", true},
+		{"file_linux_arm64.go", "package p\n", false},
+		{"file_windows_test.go", "package p\n", false},
+		{"file_linux_test.go", "package p\n", true},
+		{"windows.go", "package p\n", true},
+		{"file_other.go", "package p\n", true},
+		{"file_linux.go", "//go:build !linux\n\npackage p\n", false},
+		{"file.go", "//go:build windows\n\npackage p\n", false},
+		{"file.go", "// +build linux,amd64\n\npackage p\n", true},
+	}
+	for _, tt := range tests {
+		got, err := MatchesBuildTags(tt.name, []byte(tt.content), linux)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("MatchesBuildTags(%q, %q) = %v, want %v", tt.name, tt.content, got, tt.want)
+		}
+	}
+
+	if got, _ := MatchesBuildTags("file_windows.go", []byte("package p\n"), nil); !got {
+		t.Error("a file should match when no tags are given")
+	}
+}
+
+func TestIncludeFunction(t *testing.T) {
+	src := `package p
+
+type thing struct{}
+type Thing struct{}
+
+func init() {}
+func Exported() {}
+func unexported() {}
+func (thing) Do() {}
+func (*thing) undo() {}
+func (Thing) Run() {}
+func (*Thing) stop() {}
+`
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{"default", Options{}, []string{"init", "Exported", "unexported", "Do", "undo", "Run", "stop"}},
+		{"exported only", Options{ExportedOnly: true}, []string{"init", "Exported", "Do", "Run"}},
+		{"functions", Options{Only: "functions"}, []string{"init", "Exported", "unexported"}},
+		{"methods", Options{Only: "methods"}, []string{"Do", "undo", "Run", "stop"}},
+		{"exported methods", Options{ExportedOnly: true, Only: "methods"}, []string{"Do", "Run"}},
+		{"filter by receiver", Options{Filter: regexp.MustCompile(`^thing\.`)}, []string{"Do", "undo"}},
+		{"filter keeps init out", Options{ExportedOnly: true, Filter: regexp.MustCompile(`^E`)}, []string{"Exported"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Parse(token.NewFileSet(), "test.go", []byte(src), tt.opts)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			var got []string
+			for _, fn := range info.Functions {
+				got = append(got, fn.Name)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("emitted %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComplexity(t *testing.T) {
+	info := parseSource(t, `package p
+
+func Straight() int { return 1 }
+
+func Branch(a int) int {
+	if a > 0 {
+		return 1
+	} else if a < 0 {
+		return -1
+	}
+	return 0
+}
+
+func Chain(a, b, c, d bool) bool {
+	return a && b || c && !d
+}
+
+func Loops(xs []int) (n int) {
+	for i := 0; i < 3; i++ {
+		n += i
+	}
+	for _, x := range xs {
+		n += x
+	}
+	return n
+}
+
+func Switch(x int) string {
+	switch x {
+	case 1, 2:
+		return "low"
+	case 3:
+		return "mid"
+	default:
+		return "high"
+	}
+}
+
+func Select(a, b chan int) {
+	select {
+	case <-a:
+	case v := <-b:
+		_ = v
+	default:
+	}
+}
+
+func Closure(xs []int) func() bool {
+	return func() bool {
+		for _, x := range xs {
+			if x > 0 && x < 10 {
+				return true
+			}
+		}
+		return false
+	}
+}
+`)
+
+	tests := []struct {
+		name string
+		want int
+	}{
+		{"Straight", 1},
+		{"Branch", 3},
+		{"Chain", 4},
+		{"Loops", 3},
+		{"Switch", 4},
+		{"Select", 4},
+		// A closure's branches count towards the function declaring it
+		{"Closure", 4},
+	}
+	for _, tt := range tests {
+		if got := findFunction(t, info, tt.name).Complexity; got != tt.want {
+			t.Errorf("%s complexity = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+// typeStrings parses src and returns the rendering of each parameter of F
+func typeStrings(t *testing.T, src string) []string {
+	t.Helper()
+	return findFunction(t, parseSource(t, src), "F").Parameters
+}
+
+func TestFuncTypeStrings(t *testing.T) {
+	got := typeStrings(t, `package p
+
+func F(
+	a func(),
+	b func(int, string) error,
+	c func(n int, s ...string) (int, error),
+	d func(func() bool) func(),
+) {}
+`)
+	want := []string{
+		"func()",
+		"func(int, string) (error)",
+		"func(int, ...string) (int, error)",
+		"func(func() (bool)) (func())",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("parameter types = %q, want %q", got, want)
+	}
+}
+
+func TestArrayTypeStrings(t *testing.T) {
+	got := typeStrings(t, `package p
+
+import "crypto/sha256"
+
+const N = 4
+
+func F(
+	a []int,
+	b [5]byte,
+	c [N]int,
+	d *[N * 2]string,
+	e [sha256.Size]byte,
+	f [N][]int,
+) {}
+`)
+	want := []string{"[]int", "[5]byte", "[N]int", "*[N * 2]string", "[sha256.Size]byte", "[N][]int"}
+	if !slices.Equal(got, want) {
+		t.Errorf("parameter types = %q, want %q", got, want)
+	}
+
+	// [...]T only appears in composite literals
+	expr, err := parser.ParseExpr("[...]int{1, 2}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ExtractTypeString(expr.(*ast.CompositeLit).Type); got != "[...]int" {
+		t.Errorf("[...]int renders as %q", got)
+	}
+}
+
+func TestSelectorTypeStrings(t *testing.T) {
+	info := parseSource(t, `package p
+
+import (
+	"net/http"
+	"time"
+)
+
+func F(a *http.Request, b []time.Duration, c []*http.Cookie, d map[string][]*time.Location) *[]http.Header {
+	return nil
+}
+`)
+	fn := findFunction(t, info, "F")
+	want := []string{"*http.Request", "[]time.Duration", "[]*http.Cookie", "map[string][]*time.Location"}
+	if !slices.Equal(fn.Parameters, want) {
+		t.Errorf("parameter types = %q, want %q", fn.Parameters, want)
+	}
+	if fn.Returns != "*[]http.Header" {
+		t.Errorf("returns %q, want *[]http.Header", fn.Returns)
+	}
+
+	// Deeper chains only come up in expressions, like a field of a package var
+	expr, err := parser.ParseExpr("[]*a.b.C")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ExtractTypeString(expr); got != "[]*a.b.C" {
+		t.Errorf("[]*a.b.C renders as %q", got)
+	}
+}
+
+func TestIsRecursive(t *testing.T) {
+	info := parseSource(t, `package p
+
+type Tree struct{ kids []*Tree }
+type List[T any] struct{ next *List[T] }
+
+func Fact(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return n * Fact(n-1)
+}
+
+func Map[T any](xs []T, f func(T) T) []T {
+	if len(xs) == 0 {
+		return nil
+	}
+	return append(Map[T](xs[1:], f), f(xs[0]))
+}
+
+func (t *Tree) walk(visit func(*Tree)) {
+	visit(t)
+	for _, kid := range t.kids {
+		kid.walk(visit)
+	}
+	t.walk(nil)
+}
+
+func (t Tree) Size() int { return Tree.Size(t) }
+
+func (l *List[T]) Len() int { return 1 + l.next.Len() }
+
+func Flat(n int) int { return n }
+
+func Other(t *Tree) { t.walk(nil) }
+`)
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"Fact", true},
+		{"Map", true},
+		{"walk", true},
+		{"Size", true},
+		// l.next.Len isn't a call through the receiver itself
+		{"Len", false},
+		{"Flat", false},
+		{"Other", false},
+	}
+	for _, tt := range tests {
+		if got := findFunction(t, info, tt.name).IsRecursive; got != tt.want {
+			t.Errorf("%s recursive = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDeferGoPanic(t *testing.T) {
+	info := parseSource(t, `package p
+
+import "net/http"
+
+type server struct{}
+
+func (s *server) run() {}
+
+func worker(ch chan int) {}
+
+func Start(s *server, ch chan int, fns []func()) {
+	defer close(ch)
+	go worker(ch)
+	go s.run()
+	go func() {
+		panic("unreachable")
+	}()
+	go http.ListenAndServe(":8080", nil)
+	go fns[0]()
+	for i := 0; i < 3; i++ {
+		go worker(ch)
+	}
+}
+
+func Quiet() int { return 1 }
+`)
+
+	start := findFunction(t, info, "Start")
+	if !start.HasDefer || !start.HasGoroutine || !start.HasPanic {
+		t.Errorf("Start: defer %v, goroutine %v, panic %v; want all true", start.HasDefer, start.HasGoroutine, start.HasPanic)
+	}
+	want := []string{"worker", "s.run", "func@15", "http.ListenAndServe", "fns[0]", "worker"}
+	if !slices.Equal(start.GoCallSites, want) {
+		t.Errorf("go call sites = %q, want %q", start.GoCallSites, want)
+	}
+
+	quiet := findFunction(t, info, "Quiet")
+	if quiet.HasDefer || quiet.HasGoroutine || quiet.HasPanic || len(quiet.GoCallSites) != 0 {
+		t.Errorf("Quiet: defer %v, goroutine %v, panic %v, go call sites %q; want none",
+			quiet.HasDefer, quiet.HasGoroutine, quiet.HasPanic, quiet.GoCallSites)
+	}
+}
+
+func TestGroupPackagesSkipsUnparsedFiles(t *testing.T) {
+	broken, _ := ParseSource("pkg/broken.go", []byte("not go at all\n"))
+	files := map[string]FileInfo{
+		"pkg/a.go":      parseSource(t, "package p\n"),
+		"pkg/broken.go": *broken,
+	}
+	packages := GroupPackages(files)
+	if len(packages) != 1 || packages[0].Name != "p" || !slices.Equal(packages[0].Files, []string{"pkg/a.go"}) {
+		t.Errorf("packages = %+v, want only p with pkg/a.go", packages)
+	}
+}
+
+func TestParseMarkdownFilesGroupsPackages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "doc.md")
+	doc := "# Doc\n\n```go\nfunc A() {}\n```\n\n```go\npackage lib\n\nfunc B() {}\n```\n"
+	if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	multi := ParseMarkdownFiles(token.NewFileSet(), []string{path}, DefaultOptions())
+	if len(multi.Files) != 2 {
+		t.Fatalf("got %d blocks, want 2", len(multi.Files))
+	}
+	var names []string
+	for _, pkg := range multi.Packages {
+		names = append(names, pkg.Name)
+	}
+	if !slices.Equal(names, []string{"lib", "main"}) {
+		t.Errorf("packages = %q, want [lib main]", names)
+	}
+}
+
+func TestConstFoldingNeverPanics(t *testing.T) {
+	info := parseSource(t, `package p
+
+const (
+	FloatShift    = 1 << 2.0
+	FloatOperand  = 4.0 >> 1
+	FracShift     = 1 << 2.5
+	NegShift      = 1 << -1
+	RemZero       = 5 % 0
+	QuoZero       = 5 / 0
+	FloatQuoZero  = 5.0 / 0.0
+	ComplexZero   = 1i / 0
+	FloatRem      = 5.5 % 2
+	StringPlusInt = "a" + 1
+	BoolPlus      = true + false
+	StringLess    = "a" < "b"
+	BoolLess      = true < false
+	ComplexLess   = 1i < 2i
+	NotInt        = !1
+	NegString     = -"a"
+	XorFloat      = ^1.5
+	Mixed         = 1 + 2.5
+	Concat        = "a" + "b"
+	BoolAnd       = true && !false
+)
+`)
+
+	tests := []struct {
+		name, want string
+	}{
+		{"FloatShift", "4"},
+		{"FloatOperand", "2"},
+		{"FracShift", ""},
+		{"NegShift", ""},
+		{"RemZero", ""},
+		{"QuoZero", ""},
+		{"FloatQuoZero", ""},
+		{"ComplexZero", ""},
+		{"FloatRem", ""},
+		{"StringPlusInt", ""},
+		{"BoolPlus", ""},
+		{"StringLess", "true"},
+		{"BoolLess", ""},
+		{"ComplexLess", ""},
+		{"NotInt", ""},
+		{"NegString", ""},
+		{"XorFloat", ""},
+		{"Mixed", "3.5"},
+		{"Concat", "ab"},
+		{"BoolAnd", "true"},
+	}
+	values := make(map[string]string)
+	for _, c := range info.Constants {
+		values[c.Name] = c.EvaluatedValue
+	}
+	for _, tt := range tests {
+		if got, ok := values[tt.name]; !ok || got != tt.want {
+			t.Errorf("%s evaluates to %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIotaConstants(t *testing.T) {
+	info := parseSource(t, `package p
+
+import "os"
+
+type Flag uint
+
+const (
+	None = iota
+	One
+	Two
+)
+
+const (
+	First = iota + 1
+	Second
+	_
+	Fourth
+)
+
+const (
+	Read Flag = 1 << iota
+	Write
+	Exec
+)
+
+const (
+	KB, KiB = 1000 << (10 * iota), 1 << (10 * iota)
+	MB, MiB
+)
+
+const Mode = os.ModePerm | 1<<iota
+`)
+
+	tests := []struct {
+		name, value, evaluated string
+	}{
+		{"None", "iota", "0"},
+		{"One", "iota", "1"},
+		{"Two", "iota", "2"},
+		// iota restarts in each const block
+		{"First", "iota + 1", "1"},
+		{"Second", "iota + 1", "2"},
+		{"Fourth", "iota + 1", "4"},
+		{"Read", "1 << iota", "1"},
+		{"Write", "1 << iota", "2"},
+		{"Exec", "1 << iota", "4"},
+		{"KB", "1000 << (10 * iota)", "1000"},
+		{"KiB", "1 << (10 * iota)", "1"},
+		{"MB", "1000 << (10 * iota)", "1024000"},
+		{"MiB", "1 << (10 * iota)", "1024"},
+		// Anything relying on another package keeps only its expression
+		{"Mode", "os.ModePerm | 1<<iota", ""},
+	}
+	byName := make(map[string]ValueInfo)
+	for _, c := range info.Constants {
+		byName[c.Name] = c
+	}
+	for _, tt := range tests {
+		c, ok := byName[tt.name]
+		if !ok {
+			t.Errorf("constant %s not found", tt.name)
+			continue
+		}
+		if c.Value != tt.value || c.EvaluatedValue != tt.evaluated {
+			t.Errorf("%s = %q evaluating to %q, want %q evaluating to %q",
+				tt.name, c.Value, c.EvaluatedValue, tt.value, tt.evaluated)
+		}
+	}
+}
+
+func TestDeprecation(t *testing.T) {
+	info := parseSource(t, `package p
+
+// Old does a thing.
+//
+// Deprecated: use New instead.
+func Old() {}
+
+// Older does a thing.
+//
+// Deprecated: use New, which
+// handles errors,
+// instead.
+//
+// More about Older.
+func Older() {}
+
+// Continued mentions its status mid-paragraph.
+// Deprecated: is only continuing the sentence here.
+func Continued() {}
+
+// Bare is deprecated with no note.
+//
+// Deprecated:
+func Bare() {}
+
+func Undocumented() {}
+
+/*
+Block is documented in a block comment.
+
+Deprecated: use New.
+*/
+func Block() {}
+`)
+
+	tests := []struct {
+		name       string
+		deprecated bool
+		note       string
+	}{
+		{"Old", true, "use New instead."},
+		{"Older", true, "use New, which handles errors, instead."},
+		{"Continued", false, ""},
+		{"Bare", true, ""},
+		{"Undocumented", false, ""},
+		{"Block", true, "use New."},
+	}
+	for _, tt := range tests {
+		fn := findFunction(t, info, tt.name)
+		if fn.IsDeprecated != tt.deprecated || fn.DeprecationNote != tt.note {
+			t.Errorf("%s: deprecated %t with note %q, want %t with %q",
+				tt.name, fn.IsDeprecated, fn.DeprecationNote, tt.deprecated, tt.note)
+		}
+	}
+}
+
+func TestGenericReceivers(t *testing.T) {
+	info := parseSource(t, `package p
+
+type Set[T comparable] map[T]struct{}
+
+type Map[K comparable, V any] struct{}
+
+type Plain struct{}
+
+func (s *Set[T]) Add(v T) {}
+
+func (s Set[_]) Len() int { return 0 }
+
+func (m Map[K, V]) Get(k K) V { var v V; return v }
+
+func (m *Map[Key, Value]) Put(k Key, v Value) {}
+
+func (Plain) Name() string { return "" }
+`)
+
+	tests := []struct {
+		name     string
+		receiver string
+		params   []string
+	}{
+		{"Add", "*Set[T]", []string{"T"}},
+		{"Len", "Set[_]", []string{"_"}},
+		{"Get", "Map[K, V]", []string{"K", "V"}},
+		{"Put", "*Map[Key, Value]", []string{"Key", "Value"}},
+		{"Name", "Plain", []string{}},
+	}
+	for _, tt := range tests {
+		fn := findFunction(t, info, tt.name)
+		if fn.Receiver != tt.receiver || !slices.Equal(fn.ReceiverTypeParams, tt.params) {
+			t.Errorf("%s: receiver %q with type params %q, want %q with %q",
+				tt.name, fn.Receiver, fn.ReceiverTypeParams, tt.receiver, tt.params)
+		}
+		if fn.ReceiverTypeParams == nil {
+			t.Errorf("%s: receiver type params are nil, want an empty list", tt.name)
+		}
+	}
+}
+
+func TestSingleCallerHelpersSeparateMethods(t *testing.T) {
+	info := parseSource(t, `package p
+
+type store struct{}
+
+func (s *store) load() {}
+
+func (s *store) save() {}
+
+func load() {}
+
+func flush() {}
+
+func Open() {
+	load()
+	var s store
+	s.load()
+	flush()
+}
+
+func Close() {
+	var s store
+	s.load()
+	s.save()
+	flush()
+}
+`)
+
+	// load has one caller and store.load two, save has one but is a method,
+	// and flush has two
+	if want := []string{"load"}; !slices.Equal(info.SingleCallerHelpers, want) {
+		t.Errorf("single-caller helpers = %q, want %q", info.SingleCallerHelpers, want)
+	}
+
+	tests := []struct {
+		name, receiver string
+		fanIn          int
+	}{
+		{"load", "", 1},
+		{"load", "*store", 2},
+		{"save", "*store", 1},
+		{"flush", "", 2},
+	}
+	for _, tt := range tests {
+		found := false
+		for _, fn := range info.Functions {
+			if fn.Name != tt.name || fn.Receiver != tt.receiver {
+				continue
+			}
+			found = true
+			if fn.FanIn != tt.fanIn {
+				t.Errorf("%s %s: fan-in %d, want %d", tt.receiver, tt.name, fn.FanIn, tt.fanIn)
+			}
+		}
+		if !found {
+			t.Errorf("%s %s not found", tt.receiver, tt.name)
+		}
+	}
+}
+
+func TestTypeMembers(t *testing.T) {
+	info := parseSource(t, `package p
+
+import "io"
+
+// Store keeps items
+type Store[T any] struct {
+	io.Reader
+	// Items are the stored items
+	Items []T ` + "`json:\"items\"`" + `
+	a, b  int
+}
+
+// Len counts the items
+func (s Store[T]) Len() int { return len(s.Items) }
+
+func (s *Store[T]) Add(item T) error { return nil }
+
+type Closer interface {
+	io.Reader
+	// Close releases it
+	Close() error
+}
+
+type Level int
+
+func (l Level) String() string { return "" }
+
+type Alias = Store[int]
+`)
+
+	types := make(map[string]TypeInfo)
+	for _, typ := range info.Types {
+		types[typ.Name] = typ
+	}
+	names := func(methods []MethodSignature) []string {
+		var result []string
+		for _, m := range methods {
+			result = append(result, m.Name+"("+strings.Join(m.Parameters, ", ")+") "+m.Returns)
+		}
+		return result
+	}
+
+	store := types["Store"]
+	var fields []string
+	for _, f := range store.Fields {
+		fields = append(fields, f.Name+" "+f.Type)
+	}
+	if want := []string{"Reader io.Reader", "Items []T", "a int", "b int"}; !slices.Equal(fields, want) {
+		t.Errorf("Store fields = %q, want %q", fields, want)
+	}
+	if !store.Fields[0].Embedded || store.Fields[1].Tags["json"] != "items" || store.Fields[1].DocString != "Items are the stored items" {
+		t.Errorf("Store fields lost embedding, tags or docs: %+v", store.Fields)
+	}
+	if want := []string{"Len() int"}; !slices.Equal(names(store.Methods), want) {
+		t.Errorf("Store methods = %q, want %q", names(store.Methods), want)
+	}
+	if want := []string{"Add(T) error"}; !slices.Equal(names(store.PointerMethods), want) {
+		t.Errorf("Store pointer methods = %q, want %q", names(store.PointerMethods), want)
+	}
+	if store.Methods[0].DocString != "Len counts the items" {
+		t.Errorf("Len docstring = %q", store.Methods[0].DocString)
+	}
+
+	closer := types["Closer"]
+	if want := []string{"Close() error"}; !slices.Equal(names(closer.Methods), want) || closer.Methods[0].DocString != "Close releases it" {
+		t.Errorf("Closer methods = %+v, want Close() error documented", closer.Methods)
+	}
+	if want := []string{"io.Reader"}; !slices.Equal(closer.Embedded, want) || len(closer.Fields) != 0 {
+		t.Errorf("Closer embeds %q with fields %+v, want %q and none", closer.Embedded, closer.Fields, want)
+	}
+
+	if want := []string{"String() string"}; !slices.Equal(names(types["Level"].Methods), want) {
+		t.Errorf("Level methods = %q, want %q", names(types["Level"].Methods), want)
+	}
+	alias := types["Alias"]
+	if len(alias.Fields) != 0 || len(alias.Methods) != 0 || alias.Methods == nil || alias.PointerMethods == nil {
+		t.Errorf("Alias has fields %+v and methods %+v, want empty lists", alias.Fields, alias.Methods)
+	}
+}
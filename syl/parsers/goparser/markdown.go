@@ -0,0 +1,182 @@
+package goparser
+
+import (
+	"fmt"
+	"go/token"
+	"strings"
+)
+
+// RenderMarkdown renders the exported API of files as a Markdown page: a
+// section per file with its exported functions, then its exported types with
+// their exported methods grouped under them
+func RenderMarkdown(files []FileInfo) string {
+	var b strings.Builder
+	for i, info := range files {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "# Package %s\n\n", info.Package)
+		fmt.Fprintf(&b, "`%s`\n", info.Filename)
+
+		var funcs []FunctionInfo
+		methods := make(map[string][]FunctionInfo)
+		var receivers []string
+		for _, fn := range info.Functions {
+			if !token.IsExported(fn.Name) {
+				continue
+			}
+			if !fn.IsMethod {
+				funcs = append(funcs, fn)
+				continue
+			}
+			recv := receiverTypeName(fn.Receiver)
+			if !token.IsExported(recv) {
+				continue
+			}
+			if _, ok := methods[recv]; !ok {
+				receivers = append(receivers, recv)
+			}
+			methods[recv] = append(methods[recv], fn)
+		}
+
+		if len(funcs) > 0 {
+			b.WriteString("\n## Functions\n")
+			for _, fn := range funcs {
+				writeMarkdownFunction(&b, fn, "###")
+			}
+		}
+		if len(receivers) > 0 {
+			b.WriteString("\n## Types\n")
+			for _, recv := range receivers {
+				fmt.Fprintf(&b, "\n### type %s\n", recv)
+				for _, fn := range methods[recv] {
+					writeMarkdownFunction(&b, fn, "####")
+				}
+			}
+		}
+	}
+	return b.String()
+}
+
+// writeMarkdownFunction writes one function's heading, signature, doc and
+// parameter and result tables
+func writeMarkdownFunction(b *strings.Builder, fn FunctionInfo, heading string) {
+	title := fn.Name
+	if fn.IsMethod {
+		title = receiverTypeName(fn.Receiver) + "." + fn.Name
+	}
+	fmt.Fprintf(b, "\n%s %s\n\n", heading, title)
+	fmt.Fprintf(b, "```go\n%s\n```\n", functionSignature(fn))
+	// Outputs from before doc_blocks and docstring_raw existed only have the
+	// older forms
+	if len(fn.DocBlocks) > 0 {
+		for _, block := range fn.DocBlocks {
+			if block.Kind == "code" {
+				fmt.Fprintf(b, "\n```go\n%s\n```\n", block.Text)
+				continue
+			}
+			fmt.Fprintf(b, "\n%s\n", block.Text)
+		}
+	} else if doc := fn.DocStringRaw; doc != "" {
+		fmt.Fprintf(b, "\n%s\n", doc)
+	} else if fn.DocString != "" {
+		fmt.Fprintf(b, "\n%s\n", fn.DocString)
+	}
+
+	if len(fn.Parameters) > 0 {
+		b.WriteString("\n| Parameter | Type |\n| --- | --- |\n")
+		for i, param := range fn.Parameters {
+			name := fmt.Sprint(i + 1)
+			if i < len(fn.ParameterNames) && fn.ParameterNames[i] != "" {
+				name = fn.ParameterNames[i]
+			}
+			fmt.Fprintf(b, "| %s | `%s` |\n", markdownCell(name), markdownCell(param))
+		}
+	}
+
+	returns := splitTypeList(fn.Returns)
+	if len(returns) > 0 {
+		b.WriteString("\n| Returns | Type |\n| --- | --- |\n")
+		for i, ret := range returns {
+			name := ""
+			if i < len(fn.ReturnNames) {
+				name = fn.ReturnNames[i]
+			}
+			if name == "" {
+				name = fmt.Sprint(i + 1)
+			}
+			fmt.Fprintf(b, "| %s | `%s` |\n", markdownCell(name), markdownCell(ret))
+		}
+	}
+}
+
+// functionSignature rebuilds a one-line declaration from the extracted
+// parameter names and types
+func functionSignature(fn FunctionInfo) string {
+	var b strings.Builder
+	b.WriteString("func ")
+	if fn.IsMethod {
+		fmt.Fprintf(&b, "(%s) ", fn.Receiver)
+	}
+	b.WriteString(fn.Name)
+	if len(fn.TypeParams) > 0 {
+		fmt.Fprintf(&b, "[%s]", strings.Join(fn.TypeParams, ", "))
+	}
+	params := make([]string, len(fn.Parameters))
+	for i, param := range fn.Parameters {
+		params[i] = param
+		if i < len(fn.ParameterNames) && fn.ParameterNames[i] != "" {
+			params[i] = fn.ParameterNames[i] + " " + param
+		}
+	}
+	fmt.Fprintf(&b, "(%s)", strings.Join(params, ", "))
+
+	switch returns := splitTypeList(fn.Returns); len(returns) {
+	case 0:
+	case 1:
+		b.WriteString(" " + returns[0])
+	default:
+		fmt.Fprintf(&b, " (%s)", fn.Returns)
+	}
+	return b.String()
+}
+
+// splitTypeList splits a comma-separated list of types at the top level only,
+// so func(a, b) and map[K]V stay whole
+func splitTypeList(list string) []string {
+	if list == "" {
+		return nil
+	}
+
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range list {
+		switch r {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(list[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, strings.TrimSpace(list[start:]))
+}
+
+// receiverTypeName strips the pointer and type arguments from a receiver,
+// e.g. *List[T] becomes List
+func receiverTypeName(receiver string) string {
+	name := strings.TrimPrefix(receiver, "*")
+	if i := strings.Index(name, "["); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// markdownCell escapes the pipes that would otherwise split a table cell
+func markdownCell(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
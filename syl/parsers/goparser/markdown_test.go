@@ -0,0 +1,40 @@
+package goparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdownCompositeReturns(t *testing.T) {
+	info := parseSource(t, `package p
+
+type T struct{}
+
+// All returns every T
+func All() []T { return nil }
+
+// First returns the first T and whether there was one
+func First() (t *T, ok bool) { return nil, false }
+
+// Index maps names to their T
+func (t *T) Index() map[string][]*T { return nil }
+`)
+	page := RenderMarkdown([]FileInfo{info})
+
+	for _, want := range []string{
+		"```go\nfunc All() []T\n```",
+		"```go\nfunc First() (*T, bool)\n```",
+		"```go\nfunc (*T) Index() map[string][]*T\n```",
+		"| 1 | `[]T` |",
+		"| t | `*T` |",
+		"| ok | `bool` |",
+		"| 1 | `map[string][]*T` |",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("markdown is missing %q:\n%s", want, page)
+		}
+	}
+	if strings.Contains(page, "unknown") {
+		t.Errorf("markdown contains an unrendered type:\n%s", page)
+	}
+}
@@ -0,0 +1,163 @@
+package goparser
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		name        string
+		src         string
+		wantErr     bool
+		wantPackage string
+		wantFuncs   int
+		wantSyntax  bool
+	}{
+		{name: "valid", src: "package p\n\nfunc A() {}\nfunc B() {}\n", wantPackage: "p", wantFuncs: 2},
+		{name: "empty package", src: "package p\n", wantPackage: "p"},
+		{name: "syntax error", src: "package p\n\nfunc A() {}\nfunc B( {\n", wantPackage: "p", wantFuncs: 2, wantSyntax: true},
+		{name: "not go", src: "this is not go\n", wantErr: true, wantSyntax: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := ParseSource("src.go", []byte(tt.src))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if info == nil {
+				t.Fatal("ParseSource returned a nil FileInfo")
+			}
+			if info.Filename != "src.go" || info.Package != tt.wantPackage {
+				t.Errorf("got file %q package %q, want src.go package %q", info.Filename, info.Package, tt.wantPackage)
+			}
+			if len(info.Functions) != tt.wantFuncs {
+				t.Errorf("got %d functions, want %d", len(info.Functions), tt.wantFuncs)
+			}
+			if (len(info.ParseErrors) > 0) != tt.wantSyntax {
+				t.Errorf("parse errors %q, want some %v", info.ParseErrors, tt.wantSyntax)
+			}
+		})
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, src string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantErr    bool
+		notExist   bool
+		wantFuncs  int
+		wantSyntax bool
+	}{
+		{name: "valid", path: write("valid.go", "package p\n\nfunc A() {}\n"), wantFuncs: 1},
+		{name: "syntax error", path: write("broken.go", "package p\n\nfunc A() {\n"), wantFuncs: 1, wantSyntax: true},
+		{name: "missing file", path: filepath.Join(dir, "missing.go"), wantErr: true, notExist: true},
+		{name: "directory", path: dir, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := ParseFile(tt.path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error reading %s", tt.path)
+				}
+				if tt.notExist && !errors.Is(err, fs.ErrNotExist) {
+					t.Errorf("err = %v, want a not-exist error", err)
+				}
+				if info != nil {
+					t.Errorf("got a FileInfo alongside the read error: %+v", info)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseFile: %v", err)
+			}
+			if info.Filename != tt.path {
+				t.Errorf("filename = %q, want %q", info.Filename, tt.path)
+			}
+			if len(info.Functions) != tt.wantFuncs {
+				t.Errorf("got %d functions, want %d", len(info.Functions), tt.wantFuncs)
+			}
+			if (len(info.ParseErrors) > 0) != tt.wantSyntax {
+				t.Errorf("parse errors %q, want some %v", info.ParseErrors, tt.wantSyntax)
+			}
+		})
+	}
+}
+
+// writePackage writes n small Go files to dir, every tenth of them with a
+// syntax error and every twentieth generated
+func writePackage(tb testing.TB, dir string, n int) {
+	tb.Helper()
+	for i := 0; i < n; i++ {
+		src := fmt.Sprintf("package p\n\n// F%[1]d adds\nfunc F%[1]d(a, b int) int {\n\tif a > b {\n\t\treturn a - b\n\t}\n\treturn g%[1]d(a) + b\n}\n\nfunc g%[1]d(a int) int { return a * 2 }\n", i)
+		switch {
+		case i%20 == 0:
+			src = "// Code generated by test. DO NOT EDIT.\n\n" + src
+		case i%10 == 0:
+			src += "func broken( {\n"
+		}
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("f%03d.go", i)), []byte(src), 0o644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+func TestParseFilesConcurrently(t *testing.T) {
+	dir := t.TempDir()
+	writePackage(t, dir, 200)
+
+	parse := func(jobs int) MultiFileInfo {
+		opts := DefaultOptions()
+		opts.Jobs = jobs
+		opts.SkipGenerated = true
+		return ParseFiles(token.NewFileSet(), []string{dir}, time.Time{}, opts)
+	}
+	serial, parallel := parse(1), parse(16)
+
+	broken := 0
+	for _, info := range serial.Files {
+		if len(info.ParseErrors) > 0 {
+			broken++
+		}
+	}
+	if len(serial.Files) != 190 || len(serial.SkippedFiles) != 10 || broken != 10 {
+		t.Fatalf("got %d files, %d skipped and %d with syntax errors; want 190, 10 and 10",
+			len(serial.Files), len(serial.SkippedFiles), broken)
+	}
+	if !reflect.DeepEqual(serial, parallel) {
+		t.Error("parsing with 16 workers gave a different result than with 1")
+	}
+}
+
+func BenchmarkParseFiles(b *testing.B) {
+	dir := b.TempDir()
+	writePackage(b, dir, 500)
+
+	for _, jobs := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("jobs=%d", jobs), func(b *testing.B) {
+			opts := DefaultOptions()
+			opts.Jobs = jobs
+			for i := 0; i < b.N; i++ {
+				ParseFiles(token.NewFileSet(), []string{dir}, time.Time{}, opts)
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package goparser
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// resolvedSignature is a function's parameter and result types as go/types
+// sees them, qualified by full import path
+type resolvedSignature struct {
+	parameters []string
+	returns    string
+}
+
+// funcKey identifies a function declaration by the position of its func
+// keyword, which survives parsing the same file into different FileSets
+type funcKey struct {
+	line, col int
+}
+
+// checkedFile is what type-checking found in one file: the signature of
+// each function declaration and the package-level struct types by name
+type checkedFile struct {
+	signatures map[funcKey]resolvedSignature
+	structs    map[string]*types.Struct
+}
+
+// packageTypes is the outcome of type-checking one package, shared between
+// every file of it that asks. fingerprint records the files it was checked
+// from, so an edited package is checked again.
+type packageTypes struct {
+	once        sync.Once
+	fingerprint string
+	files       map[string]checkedFile
+	err         error
+}
+
+var (
+	packageTypesCache   = make(map[string]*packageTypes)
+	packageTypesCacheMu sync.Mutex
+)
+
+// ResolveTypes type-checks the package containing path and fills in the
+// Resolved fields of info's functions. It needs a file on disk in a package
+// that compiles; anything else is reported as an error rather than guessed.
+func ResolveTypes(info *FileInfo, path string) error {
+	file, err := checkFile(path)
+	if err != nil {
+		return err
+	}
+
+	for i := range info.Functions {
+		fn := &info.Functions[i]
+		if sig, ok := file.signatures[funcKey{fn.StartLine, fn.StartCol}]; ok {
+			fn.ResolvedParameters = sig.parameters
+			fn.ResolvedReturns = sig.returns
+		}
+	}
+	return nil
+}
+
+// ResolveLayout type-checks the package containing path, like ResolveTypes,
+// and fills in the Layout of info's structs as the gc compiler lays them out
+// for arch. Generic structs have no layout until instantiated and are left
+// without one.
+func ResolveLayout(info *FileInfo, path, arch string) error {
+	sizes := types.SizesFor("gc", arch)
+	if sizes == nil {
+		return fmt.Errorf("unknown architecture %q", arch)
+	}
+	file, err := checkFile(path)
+	if err != nil {
+		return err
+	}
+
+	for i := range info.Structs {
+		if st, ok := file.structs[info.Structs[i].Name]; ok {
+			info.Structs[i].Layout = structLayout(st, sizes, arch)
+		}
+	}
+	return nil
+}
+
+// structLayout computes the offset of each field of st, the struct's size and
+// alignment, the bytes lost to padding and the size it would have with its
+// fields sorted by decreasing alignment
+func structLayout(st *types.Struct, sizes types.Sizes, arch string) *StructLayout {
+	vars := make([]*types.Var, st.NumFields())
+	for i := range vars {
+		vars[i] = st.Field(i)
+	}
+	offsets := sizes.Offsetsof(vars)
+
+	layout := &StructLayout{
+		Arch:   arch,
+		Size:   sizes.Sizeof(st),
+		Align:  sizes.Alignof(st),
+		Fields: []FieldLayout{},
+	}
+	used := int64(0)
+	for i, v := range vars {
+		size := sizes.Sizeof(v.Type())
+		used += size
+		layout.Fields = append(layout.Fields, FieldLayout{
+			Name:   v.Name(),
+			Offset: offsets[i],
+			Size:   size,
+			Align:  sizes.Alignof(v.Type()),
+		})
+	}
+	layout.Padding = layout.Size - used
+
+	sorted := append([]*types.Var{}, vars...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sizes.Alignof(sorted[i].Type()) > sizes.Alignof(sorted[j].Type())
+	})
+	layout.OptimalSize = sizes.Sizeof(types.NewStruct(sorted, nil))
+	return layout
+}
+
+// checkFile type-checks the package containing path, once per package and
+// variant until one of its files changes, and returns what was found in
+// path's file
+func checkFile(path string) (checkedFile, error) {
+	if _, isURL := PlaygroundSourceURL(path); path == "-" || isURL {
+		return checkedFile{}, fmt.Errorf("resolving types needs a file on disk, not %s", path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return checkedFile{}, err
+	}
+
+	dir, name := filepath.Split(abs)
+	files, variant, importPath, err := packageFilesFor(filepath.Clean(dir), name)
+	if err != nil {
+		return checkedFile{}, err
+	}
+
+	fingerprint, err := packageFingerprint(dir, files)
+	if err != nil {
+		return checkedFile{}, err
+	}
+
+	key := filepath.Join(dir, variant)
+	packageTypesCacheMu.Lock()
+	pkg, ok := packageTypesCache[key]
+	if !ok || pkg.fingerprint != fingerprint {
+		pkg = &packageTypes{fingerprint: fingerprint}
+		packageTypesCache[key] = pkg
+	}
+	packageTypesCacheMu.Unlock()
+
+	pkg.once.Do(func() {
+		pkg.files, pkg.err = checkPackage(dir, importPath, files)
+	})
+	if pkg.err != nil {
+		return checkedFile{}, pkg.err
+	}
+	return pkg.files[name], nil
+}
+
+// packageFingerprint identifies the current contents of a package's files by
+// their names, sizes and modification times
+func packageFingerprint(dir string, files []string) (string, error) {
+	var b strings.Builder
+	for _, file := range files {
+		info, err := os.Stat(filepath.Join(dir, file))
+		if err != nil {
+			return "", fmt.Errorf("resolving types: %w", err)
+		}
+		fmt.Fprintf(&b, "%s\x00%d\x00%d\n", file, info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+// packageFilesFor returns the files type-checked together with name: the
+// package's own files, plus its in-package tests when name is one of them, or
+// only the external tests when name belongs to the _test package. It also
+// returns the package's import path when go/build knows it.
+func packageFilesFor(dir, name string) ([]string, string, string, error) {
+	bp, err := build.Default.ImportDir(dir, build.ImportComment)
+	if err != nil {
+		if _, ok := err.(*build.NoGoError); !ok {
+			return nil, "", "", fmt.Errorf("resolving types: %w", err)
+		}
+	}
+
+	contains := func(files []string) bool {
+		for _, file := range files {
+			if file == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	importPath := bp.ImportPath
+	if importPath == "." {
+		importPath = ""
+	}
+
+	own := append(append([]string{}, bp.GoFiles...), bp.CgoFiles...)
+	switch {
+	case contains(own):
+		return own, "package", importPath, nil
+	case contains(bp.TestGoFiles):
+		return append(own, bp.TestGoFiles...), "test", importPath, nil
+	case contains(bp.XTestGoFiles):
+		if importPath != "" {
+			importPath += "_test"
+		}
+		return bp.XTestGoFiles, "xtest", importPath, nil
+	}
+	return nil, "", "", fmt.Errorf("resolving types: %s is excluded from its package by build constraints", name)
+}
+
+// checkPackage parses and type-checks files in dir, loading imports from
+// source, and records the signature of every function declaration and the
+// package-level struct types by file. Without a known import path the package
+// is named after its clause.
+func checkPackage(dir, importPath string, names []string) (map[string]checkedFile, error) {
+	fSet := token.NewFileSet()
+	files := make([]*ast.File, 0, len(names))
+	for _, name := range names {
+		file, err := parser.ParseFile(fSet, filepath.Join(dir, name), nil, 0)
+		if err != nil {
+			return nil, fmt.Errorf("resolving types: %w", err)
+		}
+		files = append(files, file)
+	}
+
+	conf := types.Config{
+		Importer:    importer.ForCompiler(fSet, "source", nil),
+		FakeImportC: true,
+	}
+	info := &types.Info{Defs: make(map[*ast.Ident]types.Object)}
+	if importPath == "" {
+		importPath = files[0].Name.Name
+	}
+	if _, err := conf.Check(importPath, fSet, files, info); err != nil {
+		return nil, fmt.Errorf("resolving types: package in %s does not type-check: %w", dir, err)
+	}
+
+	// Qualify by full import path, e.g. net/http.Request
+	qualifier := func(pkg *types.Package) string { return pkg.Path() }
+
+	result := make(map[string]checkedFile)
+	for i, file := range files {
+		signatures := make(map[funcKey]resolvedSignature)
+		structs := make(map[string]*types.Struct)
+		for _, decl := range file.Decls {
+			if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.TYPE {
+				for _, spec := range genDecl.Specs {
+					typeSpec := spec.(*ast.TypeSpec)
+					obj, ok := info.Defs[typeSpec.Name].(*types.TypeName)
+					if !ok || typeSpec.TypeParams != nil {
+						continue
+					}
+					if st, ok := obj.Type().Underlying().(*types.Struct); ok {
+						structs[typeSpec.Name.Name] = st
+					}
+				}
+				continue
+			}
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			obj, ok := info.Defs[fn.Name].(*types.Func)
+			if !ok {
+				continue
+			}
+			sig := obj.Type().(*types.Signature)
+
+			params := []string{}
+			for j := 0; j < sig.Params().Len(); j++ {
+				t := sig.Params().At(j).Type()
+				if sig.Variadic() && j == sig.Params().Len()-1 {
+					params = append(params, "..."+types.TypeString(t.(*types.Slice).Elem(), qualifier))
+					continue
+				}
+				params = append(params, types.TypeString(t, qualifier))
+			}
+			var results []string
+			for j := 0; j < sig.Results().Len(); j++ {
+				results = append(results, types.TypeString(sig.Results().At(j).Type(), qualifier))
+			}
+
+			pos := fSet.Position(fn.Pos())
+			signatures[funcKey{pos.Line, pos.Column}] = resolvedSignature{
+				parameters: params,
+				returns:    strings.Join(results, ", "),
+			}
+		}
+		result[names[i]] = checkedFile{signatures: signatures, structs: structs}
+	}
+	return result, nil
+}
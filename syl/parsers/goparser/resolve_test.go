@@ -0,0 +1,37 @@
+package goparser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResolveTypesSeesEditedPackage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.go")
+	resolve := func(src string, mtime time.Time) string {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		info, err := ParseFile(path)
+		if err != nil {
+			t.Fatalf("ParseFile: %v", err)
+		}
+		if err := ResolveTypes(info, path); err != nil {
+			t.Fatalf("ResolveTypes: %v", err)
+		}
+		return findFunction(t, *info, "F").ResolvedReturns
+	}
+
+	start := time.Now().Add(-time.Hour)
+	if got := resolve("package p\n\nfunc F() int { return 0 }\n", start); got != "int" {
+		t.Fatalf("first check resolved %q, want int", got)
+	}
+	if got := resolve("package p\n\nfunc F() string { return \"\" }\n", start.Add(time.Minute)); got != "string" {
+		t.Errorf("after editing the file resolved %q, want string", got)
+	}
+}
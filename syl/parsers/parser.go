@@ -1,270 +1,622 @@
+// Command parser is the CLI around goparser, printing what it extracts from
+// Go source as JSON.
 package main
 
 import (
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"go/ast"
-	"go/parser"
 	"go/token"
+	"go/types"
+	"io"
 	"os"
+	"regexp"
+	"runtime"
+	"sort"
 	"strings"
+	"time"
+
+	"github.com/ohtzz/syl/syl/parsers/goparser"
+)
+
+var (
+	mergeMode     = flag.Bool("merge", false, "merge previously produced JSON outputs into a single document")
+	diffMode      = flag.Bool("diff", false, "compare the functions of two files, reporting added, removed and changed signatures")
+	matrixMode    = flag.Bool("matrix", false, "emit the local call graph as an adjacency matrix")
+	topoMode      = flag.Bool("topo", false, "emit functions in topological order of the local call graph")
+	canonicalMode = flag.Bool("canonical", false, "emit fully deterministic, pretty-printed output suitable for golden files")
+	prettyMode    = flag.Bool("pretty", false, "indent JSON output by two spaces")
+	strictMode    = flag.Bool("strict", false, "fail when any type can only be rendered as \"unknown\"")
+	fromMarkdown  = flag.Bool("from-markdown", false, "parse the ```go fenced code blocks of Markdown files, one result per block")
+	describeMode  = flag.Bool("describe", false, "print a JSON Schema of the output format for this version and exit")
+	lintDocs      = flag.Bool("lint-docs", false, "fail when an exported function or method has no doc comment, listing each one")
+
+	modifiedSinceFlag  = flag.String("modified-since", "", "only parse files modified after this duration ago (e.g. 2h) or time (RFC 3339 or 2006-01-02)")
+	annotationPrefixes = flag.String("annotation-prefixes", "+,@", "comma-separated comment prefixes collected as annotations; empty collects none")
+	exportedOnly       = flag.Bool("exported-only", false, "only emit exported functions and methods (init is always kept)")
+	jobsFlag           = flag.Int("jobs", 0, "number of files to parse concurrently (default GOMAXPROCS)")
+	buildTagsFlag      = flag.String("tags", "", "comma-separated build tags; files whose build constraints don't match are skipped")
+	skipGenerated      = flag.Bool("skip-generated", false, "skip files marked \"// Code generated ... DO NOT EDIT.\", listing them under skipped_files")
+	filterFlag         = flag.String("filter", "", "only emit functions whose name, or Receiver.Method for methods, matches this regexp")
+	onlyFlag           = flag.String("only", "", "only emit methods or only free functions: methods or functions (default both)")
+	errorTypes         = flag.String("error-types", "", "comma-separated result types besides error that count as errors, e.g. xerrors.Error")
+	resolveTypes       = flag.Bool("resolve-types", false, "type-check each file's package with go/types and add fully qualified signatures")
+	layoutMode         = flag.Bool("layout", false, "type-check each file's package with go/types and report struct field offsets, sizes and padding")
+	layoutArch         = flag.String("layout-arch", runtime.GOARCH, "architecture whose sizes and alignments -layout uses")
+	findUnused         = flag.Bool("find-unused", false, "list unexported functions nothing else in the file refers to (a single-file heuristic)")
+	cacheFlag          = flag.Bool("cache", false, "reuse parse results of unchanged files from earlier runs, stored under -cache-dir")
+	cacheDir           = flag.String("cache-dir", "", "directory for -cache, implying it (default syl under the user cache directory); runs go uncached when it can't be written")
+	functionOrder      = flag.String("order", "source", "order of each file's functions: source, or topo for callees before callers")
+	outputPath         = flag.String("o", "", "write the output to this file instead of stdout, replacing its contents only once output is ready")
+	outputFormat       = flag.String("format", "json", "output format: json, yaml, ndjson (one file per line), dot (Graphviz call graph) or markdown (API docs)")
 )
 
-type FunctionInfo struct {
-	Name       string   `json:"name"`
-	StartLine  int      `json:"start_line"`
-	EndLine    int      `json:"end_line"`
-	Parameters []string `json:"parameters"`
-	Returns    string   `json:"returns"`
-	Calls      []string `json:"calls"`
-	IsMethod   bool     `json:"is_method"`
-	Receiver   string   `json:"receiver"`
-	DocString  string   `json:"docstring"`
-	RawCode    string   `json:"raw_code"`
+// out is where output goes: stdout, or the -o file. Neither is buffered.
+var out io.Writer = os.Stdout
+
+// outFile is the -o file, if any, which main closes after run
+var outFile *outputFile
+
+// outputFile is the -o file. It is opened up front so a bad path is reported
+// before any work is done, but only truncated once output is written, so a
+// run that fails on its flags or its input leaves the previous output in
+// place.
+//
+// Output is written with fmt.Fprint and friends, whose errors go unchecked,
+// so the first write error is kept for Close to report.
+type outputFile struct {
+	file      *os.File
+	truncated bool
+	err       error
 }
 
-type FileInfo struct {
-	Functions []FunctionInfo `json:"functions"`
-	Imports   []string       `json:"imports"`
+// openOutput opens path for writing without truncating it, creating it if
+// needed
+func openOutput(path string) (*outputFile, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0o666)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	// Devices and pipes such as /dev/stdout can't be truncated, nor need to be
+	return &outputFile{file: file, truncated: !stat.Mode().IsRegular()}, nil
 }
 
-// extractFunctionCalls returns function calls inside the node
-func extractFunctionCalls(node ast.Node) []string {
-	calls := make(map[string]bool)
-
-	ast.Inspect(node, func(n ast.Node) bool {
-		switch x := n.(type) {
-		case *ast.CallExpr:
-			switch fun := x.Fun.(type) {
-			case *ast.Ident:
-				calls[fun.Name] = true
-			case *ast.SelectorExpr:
-				calls[fun.Sel.Name] = true
-			}
+func (o *outputFile) Write(p []byte) (int, error) {
+	if !o.truncated {
+		if err := o.file.Truncate(0); err != nil {
+			return 0, err
 		}
-		return true
-	})
-
-	result := make([]string, 0, len(calls))
-	for call := range calls {
-		result = append(result, call)
+		o.truncated = true
+	}
+	n, err := o.file.Write(p)
+	if err != nil && o.err == nil {
+		o.err = err
 	}
-	return result
+	return n, err
 }
 
-// extractParameters returns the parameter types
-// extractParameters returns the parameter types
-func extractParameters(params *ast.FieldList) []string {
-	if params == nil {
-		return []string{}
+// Close truncates the file if nothing was written, since the run succeeded
+// with empty output, and closes it. It reports the first failed write too.
+func (o *outputFile) Close() error {
+	if !o.truncated {
+		if err := o.file.Truncate(0); err != nil {
+			o.file.Close()
+			return err
+		}
+	}
+	err := o.file.Close()
+	if o.err != nil {
+		return o.err
 	}
+	return err
+}
 
-	var result []string
-	for _, param := range params.List {
-		paramType := extractTypeString(param.Type)
+// writeNDJSON writes info as one line of JSON. Output isn't buffered, so the
+// line is available to the reader as soon as it is written.
+func writeNDJSON(info goparser.FileInfo) {
+	line, err := json.Marshal(info)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error marshaling %s: %v\n", info.Filename, err)
+		return
+	}
+	out.Write(append(line, '\n'))
+}
 
-		// Handle multiple parameter names with the same type (e.g., "a, b int")
-		if len(param.Names) == 0 {
-			// Anonymous parameter
-			result = append(result, paramType)
-		} else {
-			// Named parameters - each name gets the same type
-			for range param.Names {
-				result = append(result, paramType)
-			}
+// reportMissingDocs prints each exported function of info that lacks a doc
+// comment and reports whether there were any
+func reportMissingDocs(info goparser.FileInfo) bool {
+	missing := false
+	for _, fn := range info.Functions {
+		if !fn.MissingDoc {
+			continue
+		}
+		name := fn.Name
+		if fn.IsMethod {
+			name = strings.TrimPrefix(fn.Receiver, "*") + "." + fn.Name
 		}
+		fmt.Fprintf(os.Stderr, "%s:%d: %s has no doc comment\n", info.Filename, fn.StartLine, name)
+		missing = true
 	}
-	return result
+	return missing
 }
 
-// extractTypeString converts an ast.Expr representing a type to its string repr
-func extractTypeString(expr ast.Expr) string {
-	switch t := expr.(type) {
-	case *ast.Ident:
-		return t.Name
+// arrangeFileInfo applies -canonical and -order to a file's output. Call
+// cycles that keep -order=topo from being strict are noted on stderr.
+func arrangeFileInfo(info *goparser.FileInfo) {
+	if *canonicalMode {
+		goparser.CanonicalizeFileInfo(info)
+	}
+	if *functionOrder != "topo" {
+		return
+	}
+
+	var cycles [][]string
+	info.Functions, cycles = goparser.OrderTopologically(info.Functions)
+	for _, cycle := range cycles {
+		if len(cycle) == 1 {
+			fmt.Fprintf(os.Stderr, "Note: %s: %s is recursive\n", info.Filename, cycle[0])
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "Note: %s: %s call each other and are kept in source order\n", info.Filename, strings.Join(cycle, ", "))
+	}
+}
 
-	case *ast.StarExpr:
-		return "*" + extractTypeString(t.X)
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
 
-	case *ast.ArrayType:
-		if t.Len == nil {
-			// Slice
-			return "[]" + extractTypeString(t.Elt)
+// parseModifiedSince accepts either a duration meaning "that long ago" or an
+// absolute time
+func parseModifiedSince(value string) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
 		}
-		// Array -- for simplicity, we'll show it as []type
-		return "[]" + extractTypeString(t.Elt)
+	}
+	return time.Time{}, fmt.Errorf("invalid -modified-since value %q: expected a duration or a time", value)
+}
 
-	case *ast.MapType:
-		return "map[" + extractTypeString(t.Key) + "]" + extractTypeString(t.Value)
+// marshalOutput encodes the result in the -format, indenting JSON with
+// -pretty or in canonical mode. Map keys are always sorted.
+func marshalOutput(v interface{}) ([]byte, error) {
+	if *outputFormat == "yaml" {
+		return marshalYAML(v)
+	}
+	if *prettyMode || *canonicalMode {
+		return json.MarshalIndent(v, "", "  ")
+	}
+	return json.Marshal(v)
+}
 
-	case *ast.ChanType:
-		switch t.Dir {
-		case ast.SEND:
-			return "chan<- " + extractTypeString(t.Value)
-		case ast.RECV:
-			return "<-chan " + extractTypeString(t.Value)
-		default:
-			return "chan " + extractTypeString(t.Value)
+// mergeOutputs combines previously produced outputs, either single FileInfo
+// documents or MultiFileInfo wrappers, into one document keyed by file name.
+// When the same file shows up more than once the last one wins.
+func mergeOutputs(paths []string) (goparser.MultiFileInfo, error) {
+	merged := goparser.MultiFileInfo{
+		SchemaVersion: goparser.SchemaVersion,
+		Files:         make(map[string]goparser.FileInfo),
+		Errors:        make(map[string]string),
+	}
+
+	add := func(name string, info goparser.FileInfo, source string) {
+		if _, ok := merged.Files[name]; ok {
+			fmt.Fprintf(os.Stderr, "Duplicate entry for %s, keeping the one from %s\n", name, source)
 		}
+		if info.SchemaVersion != goparser.SchemaVersion {
+			fmt.Fprintf(os.Stderr, "Warning: %s in %s has schema version %d, expected %d\n", name, source, info.SchemaVersion, goparser.SchemaVersion)
+		}
+		merged.Files[name] = info
+	}
 
-	case *ast.FuncType:
-		return "func" // Simplified - could be expanded to show full signature
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return merged, fmt.Errorf("reading %s: %w", path, err)
+		}
 
-	case *ast.InterfaceType:
-		if len(t.Methods.List) == 0 {
-			return "interface{}"
+		var multi goparser.MultiFileInfo
+		if err := json.Unmarshal(content, &multi); err != nil {
+			return merged, fmt.Errorf("decoding %s: %w", path, err)
+		}
+		if multi.Files != nil {
+			for name, info := range multi.Files {
+				add(name, info, path)
+			}
+			for name, msg := range multi.Errors {
+				merged.Errors[name] = msg
+			}
+			merged.SkippedFiles = append(merged.SkippedFiles, multi.SkippedFiles...)
+			continue
 		}
-		return "interface{...}" // Simplified
 
-	case *ast.StructType:
-		return "struct{...}" // Simplified
+		var info goparser.FileInfo
+		if err := json.Unmarshal(content, &info); err != nil {
+			return merged, fmt.Errorf("decoding %s: %w", path, err)
+		}
+		name := info.Filename
+		if name == "" {
+			name = path
+		}
+		add(name, info, path)
+	}
+	sort.Strings(merged.SkippedFiles)
+	merged.Packages = goparser.GroupPackages(merged.Files)
+	return merged, nil
+}
 
-	case *ast.SelectorExpr:
-		if x, ok := t.X.(*ast.Ident); ok {
-			return x.Name + "." + t.Sel.Name
+func main() {
+	code := run()
+	if outFile != nil {
+		// A run that failed leaves the output it didn't get to replace
+		closeFile := outFile.file.Close
+		if code == 0 {
+			closeFile = outFile.Close
+		}
+		if err := closeFile(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", *outputPath, err)
+			code = 1
 		}
-		return "unknown.selector"
+	}
+	os.Exit(code)
+}
 
-	case *ast.Ellipsis:
-		return "..." + extractTypeString(t.Elt)
+// run does the work of main and returns the exit code. The -o file is left
+// open for main to close once the code is known.
+func run() (code int) {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] <go-file|directory|dir/...|import-path|playground-url|->...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s -merge <output.json>...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s -describe\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s -diff <old.go> <new.go>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s -from-markdown <file.md|->...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
 
+	switch *outputFormat {
+	case "json", "yaml", "dot", "markdown", "ndjson":
 	default:
-		return "unknown"
+		fmt.Fprintf(os.Stderr, "Error: unknown -format %q, expected json, yaml, dot, markdown or ndjson\n", *outputFormat)
+		return 1
+	}
+	if *functionOrder != "source" && *functionOrder != "topo" {
+		fmt.Fprintf(os.Stderr, "Error: unknown -order %q, expected source or topo\n", *functionOrder)
+		return 1
+	}
+	if *onlyFlag != "" && *onlyFlag != "methods" && *onlyFlag != "functions" {
+		fmt.Fprintf(os.Stderr, "Error: unknown -only %q, expected methods or functions\n", *onlyFlag)
+		return 1
+	}
+	if *layoutMode && types.SizesFor("gc", *layoutArch) == nil {
+		fmt.Fprintf(os.Stderr, "Error: unknown -layout-arch %q\n", *layoutArch)
+		return 1
+	}
+	if *diffMode && *outputFormat != "json" && *outputFormat != "yaml" {
+		fmt.Fprintf(os.Stderr, "Error: -diff only supports -format=json or yaml\n")
+		return 1
+	}
+	if *outputFormat == "ndjson" && (*matrixMode || *topoMode) {
+		fmt.Fprintf(os.Stderr, "Error: -format=ndjson streams files and can't be combined with -matrix or -topo\n")
+		return 1
 	}
-}
 
-// extractReturnTypes returns return types
-func extractReturnTypes(results *ast.FieldList) string {
-	if results == nil {
-		return ""
+	if *outputPath != "" {
+		file, err := openOutput(*outputPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		outFile = file
+		out = file
 	}
 
-	var types []string
-	for _, result := range results.List {
-		switch t := result.Type.(type) {
-		case *ast.Ident:
-			types = append(types, t.Name)
-		case *ast.SelectorExpr:
-			if x, ok := t.X.(*ast.Ident); ok {
-				types = append(types, x.Name+"."+t.Sel.Name)
+	if *describeMode {
+		output, err := marshalOutput(goparser.Schema())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error marshaling output: %v\n", err)
+			return 1
+		}
+		fmt.Fprintln(out, string(output))
+		return 0
+	}
+
+	if *mergeMode {
+		if flag.NArg() == 0 {
+			flag.Usage()
+			return 1
+		}
+		merged, err := mergeOutputs(flag.Args())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error merging outputs: %v\n", err)
+			return 1
+		}
+		for name, info := range merged.Files {
+			arrangeFileInfo(&info)
+			merged.Files[name] = info
+		}
+		if *outputFormat == "ndjson" {
+			names := make([]string, 0, len(merged.Files))
+			for name := range merged.Files {
+				names = append(names, name)
 			}
-		default:
-			types = append(types, "unknown")
+			sort.Strings(names)
+			for _, name := range names {
+				writeNDJSON(merged.Files[name])
+			}
+			return 0
+		}
+		output, err := marshalOutput(merged)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error marshaling output: %v\n", err)
+			return 1
 		}
+		fmt.Fprintln(out, string(output))
+		return 0
+	}
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		return 1
+	}
+
+	target := flag.Arg(0)
+	opts := goparser.Options{
+		AnnotationPrefixes: splitList(*annotationPrefixes),
+		ExportedOnly:       *exportedOnly,
+		BuildTags:          goparser.ParseBuildTags(*buildTagsFlag),
+		SkipGenerated:      *skipGenerated,
+		ResolveTypes:       *resolveTypes,
+		FindUnused:         *findUnused,
+		Only:               *onlyFlag,
+		ErrorTypes:         splitList(*errorTypes),
+		Jobs:               *jobsFlag,
 	}
-	return strings.Join(types, ", ")
-}
 
-// extractDocstring returns the docstring cleaned up a bit
-func extractDocstring(cg *ast.CommentGroup) string {
-	if cg == nil {
-		return ""
+	if *layoutMode {
+		opts.LayoutArch = *layoutArch
 	}
 
-	var lines []string
-	for _, comment := range cg.List {
-		line := strings.TrimPrefix(comment.Text, "//")
-		line = strings.TrimSpace(line)
-		if line != "" {
-			lines = append(lines, line)
+	if *filterFlag != "" {
+		filter, err := regexp.Compile(*filterFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -filter: %v\n", err)
+			return 1
 		}
+		opts.Filter = filter
 	}
-	return strings.Join(lines, " ")
-}
 
-// extractImports returns the imports
-func extractImports(file *ast.File) []string {
-	var imports []string
+	// Files that failed still leave the rest of the output intact, but the
+	// run as a whole exits nonzero once it has been written
+	failed := false
+	defer func() {
+		if failed {
+			code = 1
+		}
+	}()
+
+	// Streamed files aren't kept, so only their unknown types are held on to
+	// for -strict
+	var streamed []goparser.FileInfo
+	if *outputFormat == "ndjson" {
+		opts.OnFile = func(name string, info goparser.FileInfo) {
+			arrangeFileInfo(&info)
+			writeNDJSON(info)
+			streamed = append(streamed, goparser.FileInfo{UnknownTypes: info.UnknownTypes})
+			if *lintDocs && reportMissingDocs(info) {
+				failed = true
+			}
+		}
+	}
+
+	// The cache only saves time, so a directory that can't be used leaves
+	// the run uncached rather than failing it
+	if *cacheFlag || *cacheDir != "" {
+		dir := *cacheDir
+		if dir == "" {
+			dir, _ = goparser.DefaultCacheDir()
+		}
+		if dir != "" {
+			if cache, err := goparser.NewCache(dir); err == nil {
+				opts.Cache = cache
+			}
+		}
+	}
+
+	if *diffMode {
+		if flag.NArg() != 2 {
+			flag.Usage()
+			return 1
+		}
+		var versions [2]goparser.FileInfo
+		for i, target := range flag.Args() {
+			filename := target
+			if target == "-" {
+				filename = goparser.StdinFilename
+			}
+			info, _, err := goparser.ParseTarget(token.NewFileSet(), filename, target, opts)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error parsing %s: %v\n", target, err)
+				return 1
+			}
+			versions[i] = info
+		}
+		output, err := marshalOutput(goparser.DiffFunctions(versions[0], versions[1]))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error marshaling output: %v\n", err)
+			return 1
+		}
+		fmt.Fprintln(out, string(output))
+		return 0
+	}
+
+	var since time.Time
+	if *modifiedSinceFlag != "" {
+		var err error
+		since, err = parseModifiedSince(*modifiedSinceFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	fSet := token.NewFileSet()
+	var result interface{}
+	var functions []goparser.FunctionInfo
+	var files []goparser.FileInfo
+
+	root, isDir := goparser.DirectoryRoot(target)
+	isImportPath := flag.NArg() == 1 && !isDir && goparser.LooksLikeImportPath(target)
+	if isDir || isImportPath || flag.NArg() > 1 || *fromMarkdown {
+		var multi goparser.MultiFileInfo
+		switch {
+		case *fromMarkdown:
+			multi = goparser.ParseMarkdownFiles(fSet, flag.Args(), opts)
+		case flag.NArg() > 1:
+			multi = goparser.ParseFiles(fSet, flag.Args(), since, opts)
+		case isImportPath:
+			var err error
+			multi, err = goparser.ParseImportPath(fSet, target, since, opts)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 1
+			}
+		default:
+			var err error
+			multi, err = goparser.ParseDirectory(fSet, root, since, opts)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error walking directory: %v\n", err)
+				return 1
+			}
+		}
+		for name, msg := range multi.Errors {
+			fmt.Fprintf(os.Stderr, "Error parsing %s: %s\n", name, msg)
+			failed = true
+		}
+		if *outputFormat == "ndjson" {
+			// Streamed output has nowhere to list them
+			for _, name := range multi.SkippedFiles {
+				fmt.Fprintf(os.Stderr, "Skipping %s: generated file\n", name)
+			}
+		}
+		for name, info := range multi.Files {
+			arrangeFileInfo(&info)
+			multi.Files[name] = info
+		}
+		result = multi
+		functions = goparser.AllFunctions(multi)
+		names := make([]string, 0, len(multi.Files))
+		for name := range multi.Files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			files = append(files, multi.Files[name])
+		}
+	} else {
+		_, isURL := goparser.PlaygroundSourceURL(target)
+		if target != "-" && !isURL && !since.IsZero() {
+			modified, err := goparser.ModifiedAfter(target, since)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+				return 1
+			}
+			if !modified {
+				fmt.Fprintf(os.Stderr, "Skipping %s: not modified since %s\n", target, since.Format(time.RFC3339))
+				return 0
+			}
+		}
 
-	for _, imp := range file.Imports {
-		path := strings.Trim(imp.Path.Value, "\"")
-		if imp.Name != nil {
-			imports = append(imports, imp.Name.Name+" "+path)
+		filename := target
+		if target == "-" {
+			filename = goparser.StdinFilename
+		}
+		fileInfo, included, err := goparser.ParseTarget(fSet, filename, target, opts)
+		if errors.Is(err, goparser.ErrGenerated) {
+			fmt.Fprintf(os.Stderr, "Skipping %s: generated file\n", target)
+			return 0
+		}
+		if err != nil && len(fileInfo.ParseErrors) == 0 {
+			fmt.Fprintf(os.Stderr, "Error parsing file: %v\n", err)
+			return 1
+		}
+		if err != nil {
+			// Still emit the FileInfo carrying the errors
+			fmt.Fprintf(os.Stderr, "Error parsing file: %v\n", err)
+			failed = true
 		} else {
-			imports = append(imports, path)
+			for _, msg := range fileInfo.ParseErrors {
+				fmt.Fprintf(os.Stderr, "Warning: %s\n", msg)
+			}
 		}
+		if !included {
+			fmt.Fprintf(os.Stderr, "Skipping %s: build constraints not satisfied by -tags\n", target)
+			return 0
+		}
+		arrangeFileInfo(&fileInfo)
+		result = fileInfo
+		functions = fileInfo.Functions
+		files = []goparser.FileInfo{fileInfo}
 	}
-	return imports
-}
 
-func main() {
-    if len(os.Args) != 2 {
-        fmt.Fprintf(os.Stderr, "Usage: %s <go-file>\n", os.Args[0])
-        os.Exit(1)
-    }
-
-    filename := os.Args[1]
-
-    content, err := os.ReadFile(filename)
-    if err != nil {
-        fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
-        os.Exit(1)
-    }
-    sourceLines := strings.Split(string(content), "\n")
-
-    fSet := token.NewFileSet()
-    node, err := parser.ParseFile(fSet, filename, nil, parser.ParseComments)
-    if err != nil {
-        fmt.Fprintf(os.Stderr, "Error parsing file: %v\n", err)
-        os.Exit(1)
-    }
-
-    fileInfo := FileInfo{
-        Functions: []FunctionInfo{},
-        Imports:   extractImports(node),
-    }
-
-    ast.Inspect(node, func(n ast.Node) bool {
-        switch x := n.(type) {
-        case *ast.FuncDecl:
-            if x.Name.IsExported() || strings.HasPrefix(x.Name.Name, "_") || x.Name.Name != "_" {
-                startPos := fSet.Position(x.Pos())
-                endPos := fSet.Position(x.End())
-
-                receiver := ""
-                isMethod := false
-                if x.Recv != nil && len(x.Recv.List) > 0 {
-                    isMethod = true
-                    switch t := x.Recv.List[0].Type.(type) {
-                    case *ast.Ident:
-                        receiver = t.Name
-                    case *ast.StarExpr:
-                        if ident, ok := t.X.(*ast.Ident); ok {
-                            receiver = "*" + ident.Name
-                        }
-                    }
-                }
-
-                rawCode := ""
-                if startPos.Line > 0 && endPos.Line > 0 && startPos.Line <= len(sourceLines) && endPos.Line <= len(sourceLines) {
-                    funcLines := sourceLines[startPos.Line-1:endPos.Line]
-                    rawCode = strings.Join(funcLines, "\n")
-                }
-
-                funcInfo := FunctionInfo{
-                    Name:       x.Name.Name,
-                    StartLine:  startPos.Line,
-                    EndLine:    endPos.Line,
-                    Parameters: extractParameters(x.Type.Params),
-                    Returns:    extractReturnTypes(x.Type.Results),
-                    Calls:      extractFunctionCalls(x),
-                    IsMethod:   isMethod,
-                    Receiver:   receiver,
-                    DocString:  extractDocstring(x.Doc),
-                    RawCode:    rawCode,
-                }
-
-                fileInfo.Functions = append(fileInfo.Functions, funcInfo)
-            }
-        }
-        return true
-    })
-
-    output, err := json.Marshal(fileInfo)
-    if err != nil {
-        fmt.Fprintf(os.Stderr, "Error marshaling JSON: %v\n", err)
-        os.Exit(1)
-    }
-
-    fmt.Println(string(output))
+	if *strictMode {
+		if count := goparser.ReportUnknownTypes(os.Stderr, append(streamed, files...)); count > 0 {
+			fmt.Fprintf(os.Stderr, "Strict mode: %d type(s) could not be rendered\n", count)
+			return 1
+		}
+	}
+
+	if *lintDocs {
+		for _, info := range files {
+			if reportMissingDocs(info) {
+				failed = true
+			}
+		}
+	}
+
+	switch *outputFormat {
+	case "ndjson":
+		// Directory and multi-file runs streamed their files through OnFile,
+		// leaving only a single parsed file here
+		for _, info := range files {
+			writeNDJSON(info)
+		}
+		return 0
+	case "dot":
+		fmt.Fprint(out, goparser.RenderDOT(functions))
+		return 0
+	case "markdown":
+		fmt.Fprint(out, goparser.RenderMarkdown(files))
+		return 0
+	}
+
+	if *matrixMode {
+		result = goparser.BuildCallMatrix(functions)
+	} else if *topoMode {
+		result = goparser.BuildTopoOrder(functions)
+	}
+
+	output, err := marshalOutput(result)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error marshaling output: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintln(out, string(output))
+	return 0
 }
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// marshalYAML encodes v as block-style YAML. Struct fields are named and
+// omitted by their json tags so the output lines up with the JSON format.
+func marshalYAML(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeYAML(&buf, reflect.ValueOf(v), 0); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// encodeYAML writes v as a block at the given indent. Scalars and empty
+// collections are written on their own line.
+func encodeYAML(buf *bytes.Buffer, v reflect.Value, indent int) error {
+	v = indirectYAML(v)
+	if scalar, ok, err := yamlScalar(v); err != nil {
+		return err
+	} else if ok {
+		buf.WriteString(strings.Repeat(" ", indent) + scalar + "\n")
+		return nil
+	}
+
+	pad := strings.Repeat(" ", indent)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			// Render the item one level deeper, then swap the indent of its
+			// first line for the list marker
+			var item bytes.Buffer
+			if err := encodeYAML(&item, v.Index(i), indent+2); err != nil {
+				return err
+			}
+			buf.WriteString(pad + "- ")
+			buf.Write(item.Bytes()[indent+2:])
+		}
+
+	case reflect.Map:
+		keys := v.MapKeys()
+		names := make([]string, len(keys))
+		values := make(map[string]reflect.Value, len(keys))
+		for i, key := range keys {
+			names[i] = fmt.Sprint(key.Interface())
+			values[names[i]] = v.MapIndex(key)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := encodeYAMLField(buf, yamlString(name), values[name], indent); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, omitEmpty := field.Name, false
+			if tag, ok := field.Tag.Lookup("json"); ok {
+				if tag == "-" {
+					continue
+				}
+				parts := strings.Split(tag, ",")
+				if parts[0] != "" {
+					name = parts[0]
+				}
+				for _, opt := range parts[1:] {
+					omitEmpty = omitEmpty || opt == "omitempty"
+				}
+			}
+			if omitEmpty && isEmptyValue(v.Field(i)) {
+				continue
+			}
+			if err := encodeYAMLField(buf, yamlString(name), v.Field(i), indent); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("yaml: unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// encodeYAMLField writes "key: value", putting non-scalar values in an
+// indented block below the key
+func encodeYAMLField(buf *bytes.Buffer, key string, v reflect.Value, indent int) error {
+	pad := strings.Repeat(" ", indent)
+	v = indirectYAML(v)
+	scalar, ok, err := yamlScalar(v)
+	if err != nil {
+		return err
+	}
+	if ok {
+		buf.WriteString(pad + key + ": " + scalar + "\n")
+		return nil
+	}
+	buf.WriteString(pad + key + ":\n")
+	return encodeYAML(buf, v, indent+2)
+}
+
+// indirectYAML unwraps interfaces and non-nil pointers
+func indirectYAML(v reflect.Value) reflect.Value {
+	for (v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr) && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+// isEmptyValue reports whether omitempty drops v, following encoding/json
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Interface, reflect.Ptr:
+		return v.IsZero()
+	}
+	return false
+}
+
+// yamlScalar renders v inline when it is a scalar, nil or an empty
+// collection. Nil matches JSON's null and empty collections use flow style.
+func yamlScalar(v reflect.Value) (string, bool, error) {
+	if !v.IsValid() {
+		return "null", true, nil
+	}
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		return "null", true, nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true, nil
+	case reflect.Float32, reflect.Float64:
+		return yamlFloat(v.Float(), v.Type().Bits()), true, nil
+	case reflect.String:
+		return yamlString(v.String()), true, nil
+	case reflect.Slice, reflect.Map:
+		if v.IsNil() {
+			return "null", true, nil
+		}
+		if v.Len() == 0 && v.Kind() == reflect.Slice {
+			return "[]", true, nil
+		}
+		if v.Len() == 0 {
+			return "{}", true, nil
+		}
+	case reflect.Array:
+		if v.Len() == 0 {
+			return "[]", true, nil
+		}
+	case reflect.Struct:
+		if v.NumField() == 0 {
+			return "{}", true, nil
+		}
+	case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		return "", false, fmt.Errorf("yaml: unsupported type %s", v.Type())
+	}
+	return "", false, nil
+}
+
+// yamlFloat formats f so YAML 1.1 reads it back as a number too, which
+// needs a dot before any exponent
+func yamlFloat(f float64, bits int) string {
+	switch {
+	case math.IsNaN(f):
+		return ".nan"
+	case math.IsInf(f, 1):
+		return ".inf"
+	case math.IsInf(f, -1):
+		return "-.inf"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, bits)
+	if mantissa, exponent, ok := strings.Cut(s, "e"); ok && !strings.Contains(mantissa, ".") {
+		s = mantissa + ".0e" + exponent
+	}
+	return s
+}
+
+// yamlString leaves s plain when YAML would read it back as the same string
+// and double-quotes it otherwise
+func yamlString(s string) string {
+	if s == "" || strings.TrimSpace(s) != s {
+		return strconv.Quote(s)
+	}
+	switch strings.ToLower(s) {
+	case "null", "~", "true", "false", "yes", "no", "on", "off", "y", "n":
+		return strconv.Quote(s)
+	}
+	// Besides indicators, a leading digit, sign or dot may make the string
+	// a number, date or sexagesimal in YAML 1.1, and = and << are the value
+	// and merge keys
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`.+=<0123456789") {
+		return strconv.Quote(s)
+	}
+	for _, r := range s {
+		if r < ' ' || r == 0x7f || r > 0x7e {
+			return strconv.Quote(s)
+		}
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return strconv.Quote(s)
+	}
+	return s
+}
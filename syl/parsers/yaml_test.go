@@ -0,0 +1,168 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestYAMLScalars(t *testing.T) {
+	tests := []struct {
+		name string
+		in   interface{}
+		want string
+	}{
+		{"plain string", "hello world", "hello world"},
+		{"string with inner colon", "a:b", "a:b"},
+		{"empty string", "", `""`},
+		{"yes", "yes", `"yes"`},
+		{"capitalized bool", "False", `"False"`},
+		{"null", "null", `"null"`},
+		{"tilde", "~", `"~"`},
+		{"float string", "1.0", `"1.0"`},
+		{"int string", "42", `"42"`},
+		{"hex string", "0x1F", `"0x1F"`},
+		{"sexagesimal string", "1:20", `"1:20"`},
+		{"date string", "2001-12-14", `"2001-12-14"`},
+		{"infinity string", ".inf", `".inf"`},
+		{"leading dash", "-x", `"-x"`},
+		{"lone dash", "-", `"-"`},
+		{"leading colon", ":x", `":x"`},
+		{"leading hash", "#x", `"#x"`},
+		{"leading star", "*x", `"*x"`},
+		{"value key", "=", `"="`},
+		{"merge key", "<<", `"<<"`},
+		{"key indicator", "a: b", `"a: b"`},
+		{"trailing colon", "a:", `"a:"`},
+		{"comment", "a #b", `"a #b"`},
+		{"leading space", " a", `" a"`},
+		{"multi-line", "a\nb", `"a\nb"`},
+		{"tab", "a\tb", `"a\tb"`},
+		{"non-ASCII", "é", `"é"`},
+		{"int", 42, "42"},
+		{"negative int", -7, "-7"},
+		{"uint", uint8(255), "255"},
+		{"bool", true, "true"},
+		{"float", 1.5, "1.5"},
+		{"whole float", 3.0, "3"},
+		{"float with exponent", 1e21, "1.0e+21"},
+		{"float32", float32(0.1), "0.1"},
+		{"NaN", math.NaN(), ".nan"},
+		{"negative infinity", math.Inf(-1), "-.inf"},
+		{"nil", nil, "null"},
+		{"nil pointer", (*int)(nil), "null"},
+		{"pointer", new(int), "0"},
+		{"nil slice", []string(nil), "null"},
+		{"empty slice", []string{}, "[]"},
+		{"nil map", map[string]int(nil), "null"},
+		{"empty map", map[string]int{}, "{}"},
+		{"empty struct", struct{}{}, "{}"},
+	}
+	for _, tt := range tests {
+		got, err := marshalYAML(tt.in)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: marshalYAML(%#v) = %s, want %s", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+type yamlTestItem struct {
+	Name     string            `json:"name"`
+	Tags     []string          `json:"tags"`
+	Meta     map[string]string `json:"meta,omitempty"`
+	Children [][]yamlTestItem  `json:"children,omitempty"`
+	Parent   *yamlTestItem     `json:"parent"`
+	Ignored  string            `json:"-"`
+	Untagged int
+	private  int
+}
+
+func TestMarshalYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   interface{}
+		want string
+	}{
+		{
+			"slice of scalars",
+			[]interface{}{"a", 1, nil, "no"},
+			"- a\n- 1\n- null\n- \"no\"",
+		},
+		{
+			"nested slices",
+			[][]int{{1, 2}, {}, {3}},
+			"- - 1\n  - 2\n- []\n- - 3",
+		},
+		{
+			"map keys sorted and quoted",
+			map[string]interface{}{"b": map[string]int{}, "a": []int{}, "-": "x", "on": 1},
+			"\"-\": x\na: []\nb: {}\n\"on\": 1",
+		},
+		{
+			"struct fields follow json tags",
+			yamlTestItem{Name: "a", Tags: []string{}, Ignored: "x", Untagged: 2, private: 3},
+			"name: a\ntags: []\nparent: null\nUntagged: 2",
+		},
+		{
+			"nested slices of structs",
+			[]yamlTestItem{{
+				Name: "a",
+				Meta: map[string]string{"k": "v: w"},
+				Children: [][]yamlTestItem{
+					{{Name: "yes", Tags: []string{"x", "- y"}}},
+					{},
+				},
+				Parent: &yamlTestItem{Name: "root"},
+			}},
+			`- name: a
+  tags: null
+  meta:
+    k: "v: w"
+  children:
+    - - name: "yes"
+        tags:
+          - x
+          - "- y"
+        parent: null
+        Untagged: 0
+    - []
+  parent:
+    name: root
+    tags: null
+    parent: null
+    Untagged: 0
+  Untagged: 0`,
+		},
+		{
+			"multi-line value",
+			map[string]string{"doc": "line one\nline two"},
+			`doc: "line one\nline two"`,
+		},
+	}
+	for _, tt := range tests {
+		got, err := marshalYAML(tt.in)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMarshalYAMLUnsupported(t *testing.T) {
+	for _, v := range []interface{}{
+		make(chan int),
+		func() {},
+		[]complex128{1i},
+		map[string]interface{}{"f": func() {}},
+	} {
+		if out, err := marshalYAML(v); err == nil {
+			t.Errorf("marshalYAML(%T) = %s, want an error", v, out)
+		}
+	}
+}